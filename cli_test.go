@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prime-run/togo/model"
+)
+
+// TestMain runs togo itself instead of the tests when TOGO_TEST_MAIN is set,
+// so that tests can run commands the way a user would and check how they exit.
+func TestMain(m *testing.M) {
+	if os.Getenv("TOGO_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// togo runs a togo command against the todos in dir without a terminal, and
+// returns its stdout, its stderr and its exit code.
+func togo(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "TOGO_TEST_MAIN=1", "TOGO_DATA_DIR="+dir, "XDG_CONFIG_HOME="+dir, "TOGO_LIST=")
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("togo %v: %v", args, err)
+	}
+	return stdout.String(), stderr.String(), 0
+}
+
+// newTogoDir returns a data directory holding todos to run commands against.
+func newTogoDir(t *testing.T, titles ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, title := range titles {
+		if stdout, _, code := togo(t, dir, "add", title); code != 0 {
+			t.Fatalf("add %q exited with %d: %s", title, code, stdout)
+		}
+	}
+	return dir
+}
+
+// TestExitCodes tests the exit code and --json error code of each kind of error
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		exit int
+		code string
+	}{
+		{"success", []string{"toggle", "Write docs"}, 0, ""},
+		{"bad deadline", []string{"add", "Ship it", "--deadline", "nope"}, 2, "parse_error"},
+		{"bad format", []string{"list", "--format", "nope"}, 2, ""},
+		{"no match", []string{"toggle", "nothing"}, 3, "not_found"},
+		{"ambiguous match", []string{"toggle", "Write"}, 4, "ambiguous"},
+		{"unconfirmed delete", []string{"delete", "Write docs"}, 5, "cancelled"},
+		{"blocked todo", []string{"toggle", "Release"}, 1, "error"},
+		{"unknown report", []string{"report", "nope"}, 3, "not_found"},
+		{"depend without --on", []string{"depend", "Release"}, 2, "parse_error"},
+		{"unknown list", []string{"move", "Release", "--to", "nope"}, 3, "not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTogoDir(t, "Write docs", "Write tests", "Release")
+			if _, _, code := togo(t, dir, "depend", "Release", "--on", "Write tests"); code != 0 {
+				t.Fatalf("depend exited with %d", code)
+			}
+
+			if _, _, code := togo(t, dir, tt.args...); code != tt.exit {
+				t.Errorf("expected exit code %d, got %d", tt.exit, code)
+			}
+			if tt.code == "" {
+				return
+			}
+			stdout, _, code := togo(t, dir, append(tt.args, "--json")...)
+			if code != tt.exit {
+				t.Errorf("expected exit code %d with --json, got %d", tt.exit, code)
+			}
+			var r struct {
+				OK    bool `json:"ok"`
+				Error *struct {
+					Code     string `json:"code"`
+					ExitCode int    `json:"exit_code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(stdout), &r); err != nil {
+				t.Fatalf("expected a JSON result, got %q: %v", stdout, err)
+			}
+			if r.Error == nil || r.Error.Code != tt.code || r.Error.ExitCode != tt.exit {
+				t.Errorf("expected error %q with exit code %d, got %+v", tt.code, tt.exit, r.Error)
+			}
+		})
+	}
+}
+
+// TestJSONWarnings tests that warnings go to stderr with --json, leaving only
+// the result on stdout
+func TestJSONWarnings(t *testing.T) {
+	dir := newTogoDir(t, "Write tests", "Release")
+	togo(t, dir, "depend", "Release", "--on", "Write tests")
+
+	stdout, stderr, code := togo(t, dir, "toggle", "Release", "--force", "--json")
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stdout)
+	}
+	var r struct {
+		OK bool `json:"ok"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &r); err != nil || !r.OK {
+		t.Errorf("expected an ok JSON result, got %q: %v", stdout, err)
+	}
+	if !strings.Contains(stderr, "Warning: completing \"Release\"") {
+		t.Errorf("expected the blocked warning on stderr, got %q", stderr)
+	}
+}
+
+// listTodos returns the todos in dir as togo list --format json prints them.
+func listTodos(t *testing.T, dir string) []model.ListItem {
+	t.Helper()
+	stdout, _, code := togo(t, dir, "list", "--all", "--format", "json")
+	if code != 0 {
+		t.Fatalf("list exited with %d: %s", code, stdout)
+	}
+	var items []model.ListItem
+	if err := json.Unmarshal([]byte(stdout), &items); err != nil {
+		t.Fatalf("expected a JSON list, got %q: %v", stdout, err)
+	}
+	return items
+}
+
+// TestNonInteractive tests that without a terminal togo fails instead of
+// asking, and acts without asking on --yes and --all-matches
+func TestNonInteractive(t *testing.T) {
+	// An ambiguous title fails and lists the todos it matched
+	dir := newTogoDir(t, "Write docs", "Write tests")
+	stdout, _, code := togo(t, dir, "toggle", "Write", "--no-input")
+	if code != 4 {
+		t.Errorf("expected exit code 4 for an ambiguous title, got %d", code)
+	}
+	if !strings.Contains(stdout, "Write docs") || !strings.Contains(stdout, "Write tests") {
+		t.Errorf("expected the matching todos to be listed, got %q", stdout)
+	}
+	for _, item := range listTodos(t, dir) {
+		if item.Completed {
+			t.Errorf("expected %q untouched after an ambiguous match", item.Title)
+		}
+	}
+
+	// --all-matches acts on every match, and delete still asks once for them
+	if _, _, code := togo(t, dir, "toggle", "Write", "--all-matches"); code != 0 {
+		t.Errorf("expected toggle --all-matches to succeed, got exit code %d", code)
+	}
+	for _, item := range listTodos(t, dir) {
+		if !item.Completed {
+			t.Errorf("expected %q completed by --all-matches", item.Title)
+		}
+	}
+	if _, _, code := togo(t, dir, "delete", "Write", "--all-matches"); code != 5 {
+		t.Errorf("expected exit code 5 for an unconfirmed delete, got %d", code)
+	}
+	if items := listTodos(t, dir); len(items) != 2 {
+		t.Errorf("expected nothing deleted without --yes, got %d todos", len(items))
+	}
+	if _, _, code := togo(t, dir, "delete", "Write", "--all-matches", "--yes"); code != 0 {
+		t.Errorf("expected delete --all-matches --yes to succeed, got exit code %d", code)
+	}
+	if items := listTodos(t, dir); len(items) != 0 {
+		t.Errorf("expected every match deleted, got %d todos", len(items))
+	}
+
+	// Archiving a todo with subtasks needs --subtasks or --yes, which
+	// archives the subtasks too
+	dir = newTogoDir(t, "Release")
+	togo(t, dir, "add", "Tag the commit", "--parent", "Release")
+	if _, _, code := togo(t, dir, "archive", "Release"); code != 5 {
+		t.Errorf("expected exit code 5 for the subtasks question, got %d", code)
+	}
+	if _, _, code := togo(t, dir, "archive", "Release", "--yes"); code != 0 {
+		t.Errorf("expected archive --yes to succeed, got exit code %d", code)
+	}
+	for _, item := range listTodos(t, dir) {
+		if !item.Archived {
+			t.Errorf("expected %q archived by --yes", item.Title)
+		}
+	}
+
+	// --yes answers the purge and restore confirmations
+	if _, _, code := togo(t, dir, "purge"); code != 5 {
+		t.Errorf("expected exit code 5 for an unconfirmed purge, got %d", code)
+	}
+	if _, _, code := togo(t, dir, "purge", "--yes"); code != 0 {
+		t.Errorf("expected purge --yes to succeed, got exit code %d", code)
+	}
+	if items := listTodos(t, dir); len(items) != 0 {
+		t.Errorf("expected the archived todos purged, got %d todos", len(items))
+	}
+	if _, _, code := togo(t, dir, "restore", "1"); code != 5 {
+		t.Errorf("expected exit code 5 for an unconfirmed restore, got %d", code)
+	}
+	if _, _, code := togo(t, dir, "restore", "1", "--yes"); code != 0 {
+		t.Errorf("expected restore --yes to succeed, got exit code %d", code)
+	}
+	if items := listTodos(t, dir); len(items) != 2 {
+		t.Errorf("expected the purged todos restored, got %d todos", len(items))
+	}
+}
+
+// TestRecurringByTitle tests that toggling a recurring todo by title
+// completes the pending occurrence instead of reopening a completed one
+func TestRecurringByTitle(t *testing.T) {
+	dir := t.TempDir()
+	togo(t, dir, "add", "Water plants", "--every", "1d")
+	for i := 0; i < 2; i++ {
+		if stdout, _, code := togo(t, dir, "toggle", "Water plants"); code != 0 {
+			t.Fatalf("toggle exited with %d: %s", code, stdout)
+		}
+	}
+
+	pending := 0
+	items := listTodos(t, dir)
+	for _, item := range items {
+		if !item.Completed {
+			pending++
+		}
+	}
+	if len(items) != 3 || pending != 1 {
+		t.Errorf("expected 3 occurrences with 1 pending, got %d with %d pending", len(items), pending)
+	}
+
+	// Several pending todos with the same title are ambiguous
+	togo(t, dir, "add", "Water plants")
+	if _, _, code := togo(t, dir, "toggle", "Water plants"); code != 4 {
+		t.Errorf("expected exit code 4 for two pending todos with the same title, got %d", code)
+	}
+}
+
+// TestPermissions tests that the todos, in either backend and in exports,
+// are readable by their owner only
+func TestPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	dir := filepath.Join(t.TempDir(), "data")
+	for _, store := range []model.Store{
+		model.NewSQLiteStore(filepath.Join(dir, "sqlite", "todos.db")),
+		model.NewJSONFileStore(filepath.Join(dir, "json", "todos.json")),
+	} {
+		todoList, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		todoList.Add("Renew passport")
+		if err := store.Save(todoList); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, mode := range map[string]os.FileMode{
+		filepath.Join(dir, "sqlite"):             0700,
+		filepath.Join(dir, "sqlite", "todos.db"): 0600,
+		filepath.Join(dir, "json"):               0700,
+		filepath.Join(dir, "json", "todos.json"): 0600,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("expected %s to have mode %o, got %o", path, mode, info.Mode().Perm())
+		}
+	}
+
+	dir = newTogoDir(t, "Renew passport")
+	output := filepath.Join(dir, "export.json")
+	if stdout, _, code := togo(t, dir, "export", "--output", output); code != 0 {
+		t.Fatalf("export exited with %d: %s", code, stdout)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the export to have mode 600, got %o", info.Mode().Perm())
+	}
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -13,17 +12,20 @@ import (
 var (
 	deadline     string
 	hardDeadline bool
+	priority     string
+	parent       string
+	every        string
 )
 
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a new todo",
-	Long:  `Add a new todo to your list. The todo will be marked as pending by default.`,
+	Long: `Add a new todo to your list. The todo will be marked as pending by default.
+Words starting with + are stored as tags, e.g. 'togo add Fix login +auth +urgent',
+and a project:name word files the todo under a project, e.g. 'project:work.api'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
-			fmt.Println("Error: Todo title is required")
-			fmt.Println("Usage: togo add <title> [--deadline <deadline>] [--hard-deadline]")
-			os.Exit(1)
+			exitWith(exitParse, "Error: Todo title is required\nUsage: togo add <title> [+tag...] [project:<name>] [--deadline <deadline>] [--hard-deadline] [--priority H|M|L] [--parent <id|title>] [--every <rule>]")
 		}
 		title := strings.Join(args, " ")
 
@@ -35,11 +37,33 @@ var addCmd = &cobra.Command{
 			var err error
 			parsedDeadline, err = model.ParseDeadline(deadline)
 			if err != nil {
-				fmt.Printf("Error parsing deadline: %v\n", err)
-				os.Exit(1)
+				exitWith(exitParse, "Error parsing deadline: %v", err)
 			}
 		}
 
+		// A repeating todo without a deadline is first due at its next occurrence
+		if every != "" {
+			recurrence, err := model.ParseRecurrence(every)
+			if err != nil {
+				exitWith(exitParse, "Error parsing repeat rule: %v", err)
+			}
+			if parsedDeadline == nil {
+				next := recurrence.Next(time.Now())
+				parsedDeadline = &next
+			}
+		}
+
+		parsedPriority, err := model.ParsePriority(priority)
+		if err != nil {
+			exitWith(exitParse, "Error parsing priority: %v", err)
+		}
+
+		var parentTodo *model.Todo
+		if parent != "" {
+			selected := resolveTodoOrExit(todoList.GetActiveTodos(), []string{parent}, "active todos", selectTodoForParent)
+			parentTodo = &selected
+		}
+
 		// Add todo with deadline
 		var todo *model.Todo
 		if parsedDeadline != nil {
@@ -47,20 +71,59 @@ var addCmd = &cobra.Command{
 		} else {
 			todo = todoList.Add(title)
 		}
+		if parsedPriority != model.PriorityNone {
+			todoList.SetPriority(todo.ID, parsedPriority)
+			todo = todoList.GetTodoByID(todo.ID)
+		}
+		if every != "" {
+			handleErrorAndExit(todoList.SetRecurrence(todo.ID, every), "Error setting repeat rule:")
+			todo = todoList.GetTodoByID(todo.ID)
+		}
+		if parentTodo != nil {
+			handleErrorAndExit(todoList.SetParent(todo.ID, parentTodo.ID), "Error setting parent:")
+			todo = todoList.GetTodoByID(todo.ID)
+		}
 		
 		saveTodoListOrExit(todoList)
+		if jsonOutput {
+			printResult(todoList, []model.Todo{*todo})
+			return
+		}
 
 		fmt.Printf("Todo added successfully with ID: %d\n", todo.ID)
 		fmt.Printf("Title: %s\n", todo.Title)
+		if parentTodo != nil {
+			done, total := todoList.Progress(parentTodo.ID)
+			fmt.Printf("Subtask of: %s (%d/%d)\n", parentTodo.Title, done, total)
+		}
+		if todo.Project != "" {
+			fmt.Printf("Project: %s\n", todo.Project)
+		}
+		if len(todo.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", model.FormatTags(todo.Tags))
+		}
+		if todo.Priority != model.PriorityNone {
+			fmt.Printf("Priority: %s\n", todo.Priority)
+		}
 		if todo.Deadline != nil {
 			deadlineStr := model.FormatDeadline(todo.Deadline, todo.HardDeadline)
 			fmt.Printf("Deadline: %s\n", deadlineStr)
 		}
+		if todo.Recurrence != "" {
+			fmt.Printf("Repeats: %s\n", model.FormatRecurrence(todo.Recurrence))
+		}
 	},
 }
 
 func init() {
 	addCmd.Flags().StringVarP(&deadline, "deadline", "d", "", "Set deadline (e.g., '2h', '1d', '2024-01-15', '2024-01-15 15:30')")
+	addCmd.Flags().StringVarP(&priority, "priority", "p", "", "Set priority: H, M, L (or high, medium, low)")
 	addCmd.Flags().BoolVarP(&hardDeadline, "hard-deadline", "", false, "Mark as hard deadline (shown with ! prefix)")
+	addCmd.Flags().StringVar(&every, "every", "", "Repeat the todo (e.g., '1d', '1w', 'weekday', 'monday', 'monthly on 1')")
+	addCmd.Flags().StringVar(&parent, "parent", "", "Add as a subtask of the todo with this ID or title")
 	rootCmd.AddCommand(addCmd)
 }
+
+func selectTodoForParent(todos []model.Todo) (model.Todo, error) {
+	return selectTodoPrompt("Select the parent todo", todos)
+}
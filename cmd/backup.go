@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage the automatic backups of your todos",
+	Long: `Before each save togo keeps a snapshot of the list as it was, so a mistake
+such as deleting the wrong todos can be undone with 'togo restore'.
+
+The last 10 snapshots of each list are kept in the backups directory of the
+data directory. Set "backups" in config.json to keep more or fewer, or to 0 to
+turn them off.`,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the snapshots of the current list",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := backupStoreOrExit()
+		backups, err := store.Backups()
+		handleErrorAndExit(err, "Error reading backups:")
+		if len(backups) == 0 {
+			fmt.Printf("No snapshots of the list \"%s\" yet. One is taken before each save.\n", ListName)
+			return
+		}
+		for i, backup := range backups {
+			count := "? todos"
+			if snapshot, err := model.LoadBackup(backup); err == nil {
+				count = countTodos(len(snapshot.Todos))
+			}
+			fmt.Printf("%2d  %s  %s  %s\n", i+1, backup.Name, model.FormatTimestamp(backup.Time), count)
+		}
+		fmt.Printf("\nKept in %s\n", store.Dir)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Restore the current list from a backup",
+	Long: `Put the current list back the way it was in a snapshot, given by its number in
+'togo backup list' (1 is the newest) or its name.
+
+What the restore would change is shown before anything is applied. The list
+is backed up as usual before it is restored, so a restore can be undone with
+another one. --yes restores it without asking.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := backupStoreOrExit()
+		backups, err := store.Backups()
+		handleErrorAndExit(err, "Error reading backups:")
+		backup, err := model.FindBackup(backups, args[0])
+		handleErrorAndExit(err, "Error:")
+		snapshot, err := model.LoadBackup(backup)
+		handleErrorAndExit(err, "Error reading snapshot:")
+		todoList := loadTodoListOrExit()
+
+		diff := model.DiffLists(todoList, snapshot)
+		if diff.Empty() {
+			fmt.Printf("The list \"%s\" is already the same as snapshot %s\n", ListName, backup.Name)
+			return
+		}
+		fmt.Printf("Restoring snapshot %s, taken %s, would:\n", backup.Name, model.FormatTimestamp(backup.Time))
+		printDiffTodos("bring back", "+", diff.Added)
+		printDiffTodos("remove", "-", diff.Removed)
+		printDiffTodos("revert", "~", diff.Changed)
+
+		confirmOrExit("Restore it")
+		todoList.Restore(snapshot)
+		todoList.Commit("restore snapshot " + backup.Name)
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Restored the list \"%s\" from snapshot %s\n", ListName, backup.Name)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		store, err := todoStore()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		if backups := findBackupStore(store); backups != nil {
+			list, _ := backups.Backups()
+			for _, backup := range list {
+				names = append(names, backup.Name)
+			}
+		}
+		return filterTitles(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+// backupStoreOrExit returns the store of the current list, which keeps its
+// backups.
+func backupStoreOrExit() *model.BackupStore {
+	store, err := todoStore()
+	handleErrorAndExit(err, "Error:")
+	if backups := findBackupStore(store); backups != nil {
+		return backups
+	}
+	exitWith(exitError, "Error: the todos are not being backed up")
+	return nil
+}
+
+// findBackupStore returns the store keeping the backups of store, if any.
+func findBackupStore(store model.Store) *model.BackupStore {
+	for {
+		switch s := store.(type) {
+		case *model.BackupStore:
+			return s
+		case *model.JournalStore:
+			store = s.Store
+		default:
+			return nil
+		}
+	}
+}
+
+func printDiffTodos(action, marker string, todos []model.Todo) {
+	if len(todos) == 0 {
+		return
+	}
+	fmt.Printf("  %s %s\n", action, countTodos(len(todos)))
+	for _, todo := range todos {
+		fmt.Printf("    %s %s\n", marker, todo.Title)
+	}
+}
+
+func countTodos(n int) string {
+	if n == 1 {
+		return "1 todo"
+	}
+	return fmt.Sprintf("%d todos", n)
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+	backupCmd.AddCommand(backupListCmd)
+}
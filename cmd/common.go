@@ -2,23 +2,62 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/manifoldco/promptui"
 	"github.com/prime-run/togo/model"
-	"os"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
 )
 
+// Store is where commands load and save todos. Until set it is the configured
+// backend in the data directory; tests can set a model.MemoryStore.
+var Store model.Store
+
+func todoStore() (model.Store, error) {
+	if Store == nil {
+		backend, err := storageBackend()
+		if err != nil {
+			return nil, err
+		}
+		store, err := storeFor(backend)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := listExists(ListName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("no list called %q, create it with 'togo lists create %s'", ListName, ListName)
+		}
+		Store = store
+	}
+	return Store, nil
+}
+
+func loadTodoList() (*model.TodoList, error) {
+	store, err := todoStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Load()
+}
+
 func loadTodoListOrExit() *model.TodoList {
-	todoList, err := model.LoadTodoList(TodoFileName)
+	todoList, err := loadTodoList()
 	if err != nil {
-		fmt.Println("Error loading todos:", err)
-		os.Exit(1)
+		exitWith(exitIO, "Error loading todos: %v", err)
 	}
 	return todoList
 }
 
 func saveTodoListOrExit(todoList *model.TodoList) {
-	if err := todoList.Save(TodoFileName); err != nil {
-		fmt.Println("Error saving todos:", err)
-		os.Exit(1)
+	store, err := todoStore()
+	if err == nil {
+		err = store.Save(todoList)
+	}
+	if err != nil {
+		exitWith(exitIO, "Error saving todos: %v", err)
 	}
 }
 
@@ -32,7 +71,259 @@ func checkEmptyTodoList(todoList *model.TodoList, emptyMessage string) bool {
 
 func handleErrorAndExit(err error, message string) {
 	if err != nil {
-		fmt.Println(message, err)
-		os.Exit(1)
+		exitWith(exitError, "%s %v", message, err)
+	}
+}
+
+// findTodoMatches looks query up in candidates: an exact (case-insensitive)
+// title wins, then a numeric ID, then the todos whose UUID starts with query,
+// then every todo whose title contains query. Several todos share a title
+// when a recurring todo is completed; then the pending one wins, or they are
+// all returned when that doesn't settle it.
+func findTodoMatches(candidates []model.Todo, query string) []model.Todo {
+	var exact, pending []model.Todo
+	for _, todo := range candidates {
+		if strings.EqualFold(todo.Title, query) {
+			exact = append(exact, todo)
+			if !todo.Completed {
+				pending = append(pending, todo)
+			}
+		}
+	}
+	if len(exact) == 1 {
+		return exact
+	}
+	if len(pending) == 1 {
+		return pending
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	if id, err := strconv.Atoi(query); err == nil {
+		for _, todo := range candidates {
+			if todo.ID == id {
+				return []model.Todo{todo}
+			}
+		}
+	}
+	if matches := model.FilterByUUIDPrefix(candidates, query); len(matches) > 0 {
+		return matches
+	}
+	var matches []model.Todo
+	for _, todo := range candidates {
+		if strings.Contains(strings.ToLower(todo.Title), strings.ToLower(query)) {
+			matches = append(matches, todo)
+		}
+	}
+	return matches
+}
+
+// resolveTodoOrExit picks the todo a command should act on. With an argument
+// it is matched against candidates and the selection prompt only opens when
+// the match is ambiguous; without one the prompt lists every candidate.
+// Prompts list the most important todos first. With --json, or when togo
+// can't prompt, an ambiguous match is an error.
+func resolveTodoOrExit(candidates []model.Todo, args []string, noun string, selectFn func([]model.Todo) (model.Todo, error)) model.Todo {
+	matches := candidates
+	if len(args) > 0 {
+		matches = findTodoMatches(candidates, args[0])
+		if len(matches) == 0 {
+			exitWith(exitNotFound, "Error: No %s found matching \"%s\"", noun, args[0])
+		}
+		if len(matches) == 1 {
+			return matches[0]
+		}
+	}
+	if jsonOutput || !interactive() {
+		if len(args) == 0 && len(matches) == 1 {
+			return matches[0]
+		}
+		exitWithMatches(exitAmbiguous, model.SortByPriority(matches), "Error: %d %s match, give an ID or UUID", len(matches), noun)
+	}
+	selectedTodo, err := selectFn(model.SortByPriority(matches))
+	if err != nil {
+		exitWith(exitCancelled, "Operation cancelled")
+	}
+	return selectedTodo
+}
+
+// addAllMatchesFlag registers the --all-matches flag of resolveTodosOrExit.
+func addAllMatchesFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-matches", false, "Act on every matching todo instead of asking which one")
+}
+
+// resolveTodosOrExit is resolveTodoOrExit for commands that can act on
+// several todos: with --all-matches every todo matching the argument is
+// picked, or every candidate without one.
+func resolveTodosOrExit(cmd *cobra.Command, candidates []model.Todo, args []string, noun string, selectFn func([]model.Todo) (model.Todo, error)) []model.Todo {
+	if all, _ := cmd.Flags().GetBool("all-matches"); !all {
+		return []model.Todo{resolveTodoOrExit(candidates, args, noun, selectFn)}
+	}
+	if len(args) == 0 {
+		return candidates
+	}
+	matches := findTodoMatches(candidates, args[0])
+	if len(matches) == 0 {
+		exitWith(exitNotFound, "Error: No %s found matching \"%s\"", noun, args[0])
+	}
+	return matches
+}
+
+// selectTodoPrompt lets the user pick one of todos, showing each todo's
+// priority and status next to its title.
+func selectTodoPrompt(label string, todos []model.Todo) (model.Todo, error) {
+	templates := &promptui.SelectTemplates{
+		Label:    "{{ . }}",
+		Active:   "▶ {{ if .Priority }}[{{ .Priority.Short | red }}] {{ end }}{{ .Title | cyan }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
+		Inactive: "  {{ if .Priority }}[{{ .Priority.Short }}] {{ end }}{{ .Title }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
+		Selected: "✓ {{ if .Priority }}[{{ .Priority.Short }}] {{ end }}{{ .Title | green }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
+	}
+	prompt := promptui.Select{
+		Label:     label,
+		Items:     todos,
+		Templates: templates,
+		Size:      10,
+	}
+	index, _, err := prompt.Run()
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return todos[index], nil
+}
+
+// addSubtasksFlag registers the --subtasks flag that decides, without
+// asking, what archive and delete do with the subtasks of their todo.
+func addSubtasksFlag(cmd *cobra.Command) {
+	cmd.Flags().String("subtasks", "", "What to do with subtasks: 'include' or 'keep' (asks by default, --yes includes them)")
+	cmd.RegisterFlagCompletionFunc("subtasks", cobra.FixedCompletions([]string{"include", "keep"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// childPolicyOrExit decides what happens to the subtasks of todo when it is
+// archived or deleted, from the --subtasks flag or by asking the user. --yes
+// answers that they go too, the same as --subtasks include.
+func childPolicyOrExit(cmd *cobra.Command, todoList *model.TodoList, todo model.Todo, action string) model.ChildPolicy {
+	children := todoList.GetDescendantIDs(todo.ID)
+	if len(children) == 0 {
+		return model.KeepChildren
+	}
+	subtasks, _ := cmd.Flags().GetString("subtasks")
+	switch subtasks {
+	case "include":
+		return model.IncludeChildren
+	case "keep":
+		return model.KeepChildren
+	case "":
+	default:
+		exitWith(exitParse, "Error: invalid --subtasks value \"%s\". Use 'include' or 'keep'", subtasks)
+	}
+	if assumeYes {
+		return model.IncludeChildren
+	}
+	if !interactive() {
+		exitWith(exitCancelled, "Error: \"%s\" has %d subtasks, use --subtasks include, --subtasks keep or --yes to %s it without a prompt", todo.Title, len(children), action)
+	}
+
+	keepLabel := "Keep subtasks"
+	if action == "delete" {
+		keepLabel = "Keep subtasks (move them up a level)"
+	}
+	prompt := promptui.Select{
+		Label:  fmt.Sprintf("\"%s\" has %d subtasks. What should happen to them", todo.Title, len(children)),
+		Items:  []string{strings.ToUpper(action[:1]) + action[1:] + " subtasks too", keepLabel, "Cancel"},
+		Stdout: promptOutput(),
+	}
+	index, _, err := prompt.Run()
+	if err != nil || index == 2 {
+		exitWith(exitCancelled, "Operation cancelled")
+	}
+	if index == 0 {
+		return model.IncludeChildren
+	}
+	return model.KeepChildren
+}
+
+// filterTitles keeps the titles containing toComplete, for shell completion.
+func filterTitles(titles []string, toComplete string) []string {
+	if toComplete == "" {
+		return titles
+	}
+	var filtered []string
+	for _, title := range titles {
+		if strings.Contains(strings.ToLower(title), strings.ToLower(toComplete)) {
+			filtered = append(filtered, title)
+		}
+	}
+	return filtered
+}
+
+// completeActiveTitles completes the titles of active todos. They are looked
+// up through model.ActiveTodos, so a SQLite list only reads those from its
+// index.
+func completeActiveTitles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeTitlesOf(model.ActiveTodos, args, toComplete)
+}
+
+// completeArchivedTitles completes the titles of archived todos.
+func completeArchivedTitles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeTitlesOf(model.ArchivedTodos, args, toComplete)
+}
+
+func completeTitlesOf(find func(model.Store) ([]model.Todo, error), args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	store, err := todoStore()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	todos, err := find(store)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = todo.Title
+	}
+	return filterTitles(titles, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// addTagFlag registers the --tag filter flag along with completion of the
+// tags already in use.
+func addTagFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringP("tag", "t", "", usage)
+	cmd.RegisterFlagCompletionFunc("tag", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		todoList, err := loadTodoList()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterTitles(todoList.GetTags(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// addQueryFlag registers the --query filter flag, see model.Query.
+func addQueryFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("query", "q", "", `Only consider todos matching this query, e.g. "status:pending +backend"`)
+}
+
+// filterByQueryOrExit narrows candidates to the todos matching --query and
+// exits when none do.
+func filterByQueryOrExit(cmd *cobra.Command, todoList *model.TodoList, candidates []model.Todo, noun string) []model.Todo {
+	text, _ := cmd.Flags().GetString("query")
+	if strings.TrimSpace(text) == "" {
+		return candidates
+	}
+	candidates = parseQueryOrExit(text).Filter(todoList, candidates)
+	if len(candidates) == 0 {
+		exitWith(exitNotFound, "No %s match the query %s", noun, text)
+	}
+	return candidates
+}
+
+// parseQueryOrExit parses a query, showing where it went wrong if it can't.
+func parseQueryOrExit(text string) *model.Query {
+	query, err := model.ParseQuery(text)
+	if queryErr, ok := err.(*model.QueryError); ok {
+		exitWith(exitParse, "Error in query at %v\n%s", err, queryErr.Pointer())
 	}
+	return query
 }
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prime-run/togo/model"
+)
+
+const (
+	storageJSON   = "json"
+	storageSQLite = "sqlite"
+)
+
+// Config holds the settings in config.json in the user's config directory,
+// e.g. ~/.config/togo/config.json.
+type Config struct {
+	// Storage selects where todos are kept: "json" (the default) or "sqlite".
+	// The TOGO_STORAGE environment variable overrides it.
+	Storage string `json:"storage,omitempty"`
+	// DefaultList is the list used when --list and TOGO_LIST are not given.
+	DefaultList string `json:"default_list,omitempty"`
+	// Backups is how many snapshots of each list are kept, taken before each
+	// save. It defaults to model.DefaultBackupCount, and 0 turns them off.
+	Backups *int `json:"backups,omitempty"`
+	// Reports are the reports run with 'togo report <name>', in addition to
+	// model.BuiltinReports, which they override.
+	Reports map[string]model.Report `json:"reports,omitempty"`
+}
+
+func configPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "togo", "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file means the defaults.
+func loadConfig() (Config, error) {
+	var config Config
+	path, err := configPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+func saveConfig(config Config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// localPath is the .togo.json file or .togo directory of the project togo
+// runs in, or "" when it uses the user's own lists.
+var localPath string
+
+// useLocal makes togo work on the todos of a project, kept in path: a
+// .togo.json file holding its one list, or a .togo directory of lists.
+func useLocal(path string) {
+	localPath = path
+	if filepath.Base(path) == model.LocalDirName {
+		model.SetDataDir(path)
+	}
+}
+
+// localFile returns the .togo.json file in use, or "".
+func localFile() string {
+	if filepath.Base(localPath) == model.LocalFileName {
+		return localPath
+	}
+	return ""
+}
+
+// storageBackend returns the configured storage backend. Project todos are
+// always kept as JSON so that they can be checked in and diffed.
+func storageBackend() (string, error) {
+	if localPath != "" {
+		return storageJSON, nil
+	}
+	if backend := os.Getenv("TOGO_STORAGE"); backend != "" {
+		return backend, nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	if config.Storage == "" {
+		return storageJSON, nil
+	}
+	return config.Storage, nil
+}
+
+// storageExt returns the file extension lists of backend are stored with.
+func storageExt(backend string) (string, error) {
+	switch strings.ToLower(backend) {
+	case storageJSON:
+		return ".json", nil
+	case storageSQLite:
+		return ".db", nil
+	}
+	return "", fmt.Errorf("unknown storage backend %q, use %q or %q", backend, storageJSON, storageSQLite)
+}
+
+// storeFor opens the store of the given backend for the current list.
+func storeFor(backend string) (model.Store, error) {
+	return storeForList(backend, ListName)
+}
+
+// storeForList opens the store of the given backend for the list name.
+// Saves are backed up, see backupDir, and recorded in a journal next to the
+// list, or in projectDir for a project's lists, so that they can be undone.
+// Encrypted todos are unlocked first.
+func storeForList(backend, name string) (model.Store, error) {
+	if err := unlockDataDir(); err != nil {
+		return nil, err
+	}
+	store, err := openStore(backend, name)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := backupDir(name)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	keep := model.DefaultBackupCount
+	if config.Backups != nil {
+		keep = *config.Backups
+	}
+	journal, err := journalPath(store, name)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewJournalStore(model.NewBackupStore(store, dir, keep), journal), nil
+}
+
+func openStore(backend, name string) (model.Store, error) {
+	if localPath != "" {
+		return openLocalStore(name)
+	}
+	ext, err := storageExt(backend)
+	if err != nil {
+		return nil, err
+	}
+	if ext == ".db" {
+		if unlocked {
+			return nil, errors.New("encrypted todos can't be kept in SQLite, use the json storage backend")
+		}
+		return model.DefaultSQLiteStore(name + ext)
+	}
+	return model.DefaultStore(name + ext)
+}
+
+// openLocalStore opens the list name of the project, taking its lock in
+// projectDir rather than next to the list.
+func openLocalStore(name string) (model.Store, error) {
+	path := localFile()
+	if path != "" && name != model.DefaultListName {
+		return nil, fmt.Errorf("%s holds a single list, use --global for list %q", path, name)
+	}
+	if path == "" {
+		path = filepath.Join(localPath, name+".json")
+	}
+	dir, err := projectDir()
+	if err != nil {
+		return nil, err
+	}
+	store := model.NewJSONFileStore(path)
+	store.LockPath = filepath.Join(dir, name+".json.lock")
+	return store, nil
+}
+
+// projectDir returns the directory the backups, journals and locks of the
+// project's lists are kept in: projects/<hash of its path> in the user's data
+// directory, so that they stay out of the repository.
+func projectDir() (string, error) {
+	dataDir, err := model.UserDataDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(localPath))
+	return filepath.Join(dataDir, model.ProjectsDir, hex.EncodeToString(sum[:6])), nil
+}
+
+// journalPath returns the undo journal of store, which holds the list name.
+// The store isn't needed for a project's lists.
+func journalPath(store model.Store, name string) (string, error) {
+	if localPath == "" {
+		return storePath(store) + ".journal", nil
+	}
+	dir, err := projectDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json.journal"), nil
+}
+
+// backupDir returns the directory snapshots of the list name are kept in:
+// backups/<name> in the data directory, or in projectDir for a project's
+// lists.
+func backupDir(name string) (string, error) {
+	dataDir, err := model.DataDir()
+	if localPath != "" {
+		dataDir, err = projectDir()
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "backups", name), nil
+}
+
+// listNames returns the lists of the configured storage backend.
+func listNames() ([]string, error) {
+	if localFile() != "" {
+		return []string{model.DefaultListName}, nil
+	}
+	backend, err := storageBackend()
+	if err != nil {
+		return nil, err
+	}
+	ext, err := storageExt(backend)
+	if err != nil {
+		return nil, err
+	}
+	return model.ListNames(ext)
+}
+
+// listExists reports whether the list name has been created. The default
+// list always exists.
+func listExists(name string) (bool, error) {
+	if name == model.DefaultListName {
+		return true, nil
+	}
+	names, err := listNames()
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range names {
+		if existing == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// defaultList returns the list used when none is chosen.
+func defaultList() string {
+	if config, err := loadConfig(); err == nil && config.DefaultList != "" {
+		return config.DefaultList
+	}
+	return model.DefaultListName
+}
@@ -2,96 +2,73 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/manifoldco/promptui"
 	"github.com/prime-run/togo/model"
 	"github.com/spf13/cobra"
-	"os"
-	"strconv"
-	"strings"
+	"slices"
 )
 
 var deleteCmd = &cobra.Command{
 	Use:   "delete <title>",
 	Short: "Delete a todo",
-	Long:  `Delete a todo from your list using its title.`,
+	Long: `Delete a todo from your list using its title.
+With --all-matches every todo matching the title, --tag and --query is deleted
+after a single confirmation, which --yes answers.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		todoList := loadTodoListOrExit()
 
-		if checkEmptyTodoList(todoList, "No todos found. Add some todos with the 'add' command.") {
-			return
+		if len(todoList.Todos) == 0 {
+			exitWith(exitNotFound, "No todos found. Add some todos with the 'add' command.")
+		}
+		tag, _ := cmd.Flags().GetString("tag")
+		candidates := model.FilterByTag(todoList.Todos, tag)
+		if len(candidates) == 0 {
+			exitWith(exitNotFound, "No todos tagged +%s found.", model.NormalizeTag(tag))
 		}
 
-		var todoTitle string
-		if len(args) > 0 {
-			todoTitle = args[0]
-			todo, found := todoList.FindByTitle(todoTitle, false)
-			if found {
-				if confirmDelete(todo.Title) {
-					todoList.Delete(todo.ID)
-					saveTodoListOrExit(todoList)
-					fmt.Printf("Todo \"%s\" deleted successfully\n", todo.Title)
-				}
-				return
+		candidates = filterByQueryOrExit(cmd, todoList, candidates, "todos")
+		selected := resolveTodosOrExit(cmd, candidates, args, "todos", selectTodo)
+		var targets []model.Todo
+		var policies []model.ChildPolicy
+		var ids []int
+		for _, selectedTodo := range selected {
+			// A subtask may already go along with its parent
+			if slices.Contains(ids, selectedTodo.ID) {
+				continue
 			}
-			id, err := strconv.Atoi(todoTitle)
-			if err == nil {
-				for _, todo := range todoList.Todos {
-					if todo.ID == id {
-						if confirmDelete(todo.Title) {
-							todoList.Delete(id)
-							saveTodoListOrExit(todoList)
-							fmt.Printf("Todo \"%s\" deleted successfully\n", todo.Title)
-						}
-						return
+			policy := childPolicyOrExit(cmd, todoList, selectedTodo, "delete")
+			targets = append(targets, selectedTodo)
+			policies = append(policies, policy)
+			ids = append(ids, selectedTodo.ID)
+			if policy == model.IncludeChildren {
+				for _, id := range todoList.GetDescendantIDs(selectedTodo.ID) {
+					if !slices.Contains(ids, id) {
+						ids = append(ids, id)
 					}
 				}
 			}
-			var matches []model.Todo
-			for _, todo := range todoList.Todos {
-				if strings.Contains(strings.ToLower(todo.Title), strings.ToLower(todoTitle)) {
-					matches = append(matches, todo)
-				}
-			}
-			if len(matches) == 0 {
-				fmt.Printf("Error: No todos found matching \"%s\"\n", todoTitle)
-				os.Exit(1)
-			} else if len(matches) == 1 {
-				if confirmDelete(matches[0].Title) {
-					todoList.Delete(matches[0].ID)
-					saveTodoListOrExit(todoList)
-					fmt.Printf("Todo \"%s\" deleted successfully\n", matches[0].Title)
-				}
-				return
-			} else {
-				selectedTodo, err := selectTodo(matches)
-				if err != nil {
-					fmt.Println("Operation cancelled")
-					os.Exit(0)
-				}
-				if confirmDelete(selectedTodo.Title) {
-					todoList.Delete(selectedTodo.ID)
-					saveTodoListOrExit(todoList)
-					fmt.Printf("Todo \"%s\" deleted successfully\n", selectedTodo.Title)
-				}
-				return
-			}
-		} else {
-			todos := todoList.Todos
-			if len(todos) == 0 {
-				fmt.Println("No todos found. Add some todos with the 'add' command.")
-				os.Exit(0)
-			}
-			selectedTodo, err := selectTodo(todos)
-			if err != nil {
-				fmt.Println("Operation cancelled")
-				os.Exit(0)
+		}
+		confirmDeleteOrExit(targets)
+
+		deleted := todosByID(todoList, ids)
+		var lines []string
+		for i, target := range targets {
+			if todoList.GetTodoByID(target.ID) == nil {
+				continue
 			}
-			if confirmDelete(selectedTodo.Title) {
-				todoList.Delete(selectedTodo.ID)
-				saveTodoListOrExit(todoList)
-				fmt.Printf("Todo \"%s\" deleted successfully\n", selectedTodo.Title)
+			count := todoList.DeleteWithChildren(target.ID, policies[i])
+			lines = append(lines, fmt.Sprintf("Todo \"%s\" deleted successfully", target.Title))
+			if count > 1 {
+				lines = append(lines, fmt.Sprintf("%d subtasks deleted with it", count-1))
 			}
 		}
+		saveTodoListOrExit(todoList)
+		if jsonOutput {
+			printResult(todoList, deleted)
+			return
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
 	},
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) != 0 {
@@ -99,53 +76,31 @@ var deleteCmd = &cobra.Command{
 		}
 
 		todoList := loadTodoListOrExit()
-		titles := todoList.GetTodoTitles()
-
-		if toComplete != "" {
-			var filtered []string
-			for _, title := range titles {
-				if strings.Contains(strings.ToLower(title), strings.ToLower(toComplete)) {
-					filtered = append(filtered, title)
-				}
-			}
-			return filtered, cobra.ShellCompDirectiveNoFileComp
-		}
-		return titles, cobra.ShellCompDirectiveNoFileComp
+		return filterTitles(todoList.GetTodoTitles(), toComplete), cobra.ShellCompDirectiveNoFileComp
 	},
 }
 
 func selectTodo(todos []model.Todo) (model.Todo, error) {
-	templates := &promptui.SelectTemplates{
-		Label:    "{{ . }}",
-		Active:   "▶ {{ .Title | cyan }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-		Inactive: "  {{ .Title }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-		Selected: "✓ {{ .Title | green }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-	}
-	prompt := promptui.Select{
-		Label:     "Select a todo to delete",
-		Items:     todos,
-		Templates: templates,
-		Size:      10,
-	}
-	index, _, err := prompt.Run()
-	if err != nil {
-		return model.Todo{}, err
-	}
-	return todos[index], nil
+	return selectTodoPrompt("Select a todo to delete", todos)
 }
 
-func confirmDelete(title string) bool {
-	prompt := promptui.Prompt{
-		Label:     fmt.Sprintf("Are you sure you want to delete \"%s\"", title),
-		IsConfirm: true,
+// confirmDeleteOrExit asks before todos are deleted, listing them when there
+// are several.
+func confirmDeleteOrExit(todos []model.Todo) {
+	if len(todos) == 1 {
+		confirmOrExit(fmt.Sprintf("Are you sure you want to delete \"%s\"", todos[0].Title))
+		return
 	}
-	result, err := prompt.Run()
-	if err != nil {
-		return false
+	if !assumeYes && !jsonOutput {
+		printDiffTodos("delete", "-", todos)
 	}
-	return strings.ToLower(result) == "y"
+	confirmOrExit(fmt.Sprintf("Are you sure you want to delete these %d todos", len(todos)))
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+	addTagFlag(deleteCmd, "Only consider todos with this tag")
+	addQueryFlag(deleteCmd)
+	addAllMatchesFlag(deleteCmd)
+	addSubtasksFlag(deleteCmd)
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var dependCmd = &cobra.Command{
+	Use:   "depend <title> --on <title>",
+	Short: "Mark a todo as blocked by another",
+	Long: `Record that a todo cannot be completed before another one. Blocked todos show
+as "Blocked" until everything they depend on is completed, and toggling them
+is refused unless --force is given. Dependencies that form a cycle are rejected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		on, _ := cmd.Flags().GetString("on")
+		if on == "" {
+			exitWith(exitParse, "Error: --on <title> is required\nUsage: togo depend <title> --on <title>")
+		}
+		candidates := todoList.GetActiveTodos()
+		if len(candidates) == 0 {
+			exitWith(exitNotFound, "No active todos found. Add some todos with the 'add' command.")
+		}
+
+		selectedTodo := resolveTodoOrExit(candidates, args, "active todos", selectTodoForDepend)
+		blocker := resolveTodoOrExit(candidates, []string{on}, "active todos", selectTodoForBlocker)
+		handleErrorAndExit(todoList.AddDependency(selectedTodo.ID, blocker.ID), "Error:")
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Todo \"%s\" now depends on \"%s\"\n", selectedTodo.Title, blocker.Title)
+	},
+	ValidArgsFunction: completeActiveTitles,
+}
+
+var undependCmd = &cobra.Command{
+	Use:   "undepend <title> [--on <title>]",
+	Short: "Remove a dependency between todos",
+	Long: `Remove a dependency added with 'togo depend'. Without --on you pick which
+of the todo's dependencies to remove.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		var withDeps []model.Todo
+		for _, todo := range todoList.Todos {
+			if len(todo.DependsOn) > 0 {
+				withDeps = append(withDeps, todo)
+			}
+		}
+		if len(withDeps) == 0 {
+			exitWith(exitNotFound, "No todos have dependencies.")
+		}
+
+		selectedTodo := resolveTodoOrExit(withDeps, args, "todos with dependencies", selectTodoForDepend)
+		var onArgs []string
+		if on, _ := cmd.Flags().GetString("on"); on != "" {
+			onArgs = []string{on}
+		}
+		deps := todoList.GetDependencies(selectedTodo.ID)
+		blocker := deps[0]
+		if len(deps) > 1 || len(onArgs) > 0 {
+			blocker = resolveTodoOrExit(deps, onArgs, "dependencies", selectTodoForBlocker)
+		}
+		handleErrorAndExit(todoList.RemoveDependency(selectedTodo.ID, blocker.ID), "Error:")
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Todo \"%s\" no longer depends on \"%s\"\n", selectedTodo.Title, blocker.Title)
+	},
+	ValidArgsFunction: completeActiveTitles,
+}
+
+func selectTodoForDepend(todos []model.Todo) (model.Todo, error) {
+	return selectTodoPrompt("Select the blocked todo", todos)
+}
+
+func selectTodoForBlocker(todos []model.Todo) (model.Todo, error) {
+	return selectTodoPrompt("Select the todo it depends on", todos)
+}
+
+func init() {
+	rootCmd.AddCommand(dependCmd)
+	rootCmd.AddCommand(undependCmd)
+	for _, c := range []*cobra.Command{dependCmd, undependCmd} {
+		c.Flags().String("on", "", "The todo it depends on (ID or title)")
+		c.RegisterFlagCompletionFunc("on", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completeActiveTitles(cmd, nil, toComplete)
+		})
+	}
+}
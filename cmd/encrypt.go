@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt your todos with a passphrase",
+	Long: `Encrypt every list in the data directory, along with the undo journals and
+backups, with AES-256-GCM and a key derived from a passphrase with scrypt.
+From then on togo needs the passphrase to read or change your todos.
+
+The passphrase comes from the file named by $TOGO_KEYFILE, from
+$TOGO_PASSPHRASE, or else is asked for, so scripts can set one of the
+variables. There is no way to recover todos if the passphrase is lost.
+
+Lists kept with the SQLite backend and project lists in a .togo.json file
+can't be encrypted. After 'togo storage migrate --to json', remove the
+SQLite files the migration left behind before encrypting.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if localFile() != "" {
+			exitWith(exitError, "Error: %s is shared with the project and can't be encrypted, use --global for your own lists", localFile())
+		}
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		if strings.EqualFold(backend, storageSQLite) {
+			exitWith(exitError, "Error: SQLite lists can't be encrypted, migrate them with 'togo storage migrate --to json' first")
+		}
+		encrypted, err := model.IsEncryptedDataDir()
+		handleErrorAndExit(err, "Error:")
+		if encrypted {
+			fmt.Println("Your todos are already encrypted")
+			return
+		}
+		passphrase, err := readPassphrase(true)
+		handleErrorAndExit(err, "Error:")
+		handleErrorAndExit(model.EncryptDataDir(passphrase), "Error encrypting todos:")
+		fmt.Println("Your todos are encrypted. Keep the passphrase safe, they can't be read without it.")
+	},
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store your todos in plain text again",
+	Long: `Decrypt every list in the data directory, along with the undo journals and
+backups, and stop asking for the passphrase.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		encrypted, err := model.IsEncryptedDataDir()
+		handleErrorAndExit(err, "Error:")
+		if !encrypted || localFile() != "" {
+			fmt.Println("Your todos are not encrypted")
+			return
+		}
+		handleErrorAndExit(unlockDataDir(), "Error:")
+		handleErrorAndExit(model.DecryptDataDir(), "Error decrypting todos:")
+		fmt.Println("Your todos are stored in plain text again")
+	},
+}
+
+// unlocked is set once the key of an encrypted data directory is known.
+var unlocked bool
+
+// unlockDataDir asks for the passphrase of the data directory if its todos
+// are encrypted, once per run. A project's .togo.json is never encrypted.
+func unlockDataDir() error {
+	if unlocked || localFile() != "" {
+		return nil
+	}
+	encrypted, err := model.IsEncryptedDataDir()
+	if err != nil || !encrypted {
+		return err
+	}
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+	if err := model.Unlock(passphrase); err != nil {
+		return err
+	}
+	unlocked = true
+	return nil
+}
+
+// readPassphrase returns the contents of $TOGO_KEYFILE, $TOGO_PASSPHRASE or
+// else asks for the passphrase, twice when confirm is set.
+func readPassphrase(confirm bool) (string, error) {
+	if path := os.Getenv("TOGO_KEYFILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read the keyfile: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv("TOGO_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !interactive() {
+		return "", errors.New("no passphrase: set TOGO_PASSPHRASE or TOGO_KEYFILE, or run togo in a terminal")
+	}
+	prompt := promptui.Prompt{Label: "Passphrase", Mask: '*', Stdout: promptOutput()}
+	passphrase, err := prompt.Run()
+	if err != nil {
+		return "", errors.New("no passphrase given")
+	}
+	if confirm {
+		prompt.Label = "Passphrase again"
+		again, err := prompt.Run()
+		if err != nil {
+			return "", errors.New("no passphrase given")
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd, decryptCmd)
+}
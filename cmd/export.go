@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export todos as JSON",
+	Long: `Export todos as JSON, to standard output or to a file with --output.
+Each todo carries a UUID, so the export can be imported into another list or
+on another machine with 'togo import' without clashing with its todos.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		tag, _ := cmd.Flags().GetString("tag")
+		output, _ := cmd.Flags().GetString("output")
+
+		todos := model.FilterByTag(todoList.Todos, tag)
+		if todos == nil {
+			todos = []model.Todo{}
+		}
+		data, err := json.MarshalIndent(model.Export{SchemaVersion: model.SchemaVersion, Todos: todos}, "", "  ")
+		handleErrorAndExit(err, "Error exporting todos:")
+		data = append(data, '\n')
+
+		if output == "" || output == "-" {
+			os.Stdout.Write(data)
+			return
+		}
+		handleErrorAndExit(os.WriteFile(output, data, 0600), "Error writing export:")
+		fmt.Printf("Exported %d todos to %s\n", len(todos), output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringP("output", "o", "", "Write the export to this file instead of standard output")
+	addTagFlag(exportCmd, "Only export todos with this tag")
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+// Output formats of 'togo list' when it prints todos instead of showing them.
+const (
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatTSV      = "tsv"
+	formatTable    = "table"
+	formatPlain    = "plain"
+	formatTemplate = "template"
+)
+
+var outputFormats = []string{formatJSON, formatCSV, formatTSV, formatTable, formatPlain}
+
+// tableColumns are the columns of the table format.
+var tableColumns = []string{"id", "status", "priority", "due", "title", "project", "tags"}
+
+func addFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", "", "Print the todos as "+strings.Join(outputFormats, ", ")+" instead of opening the interactive UI")
+	cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return filterTitles(outputFormats, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+	cmd.Flags().String("template", "", "Print each todo with a Go template, e.g. '{{.ID}} {{.Title}}'")
+}
+
+// outputFormat returns the format todos should be printed in, or "" to open
+// the interactive UI. Todos are printed as JSON with --json, and as a table
+// when stdout is not a terminal.
+func outputFormat(cmd *cobra.Command) string {
+	format, _ := cmd.Flags().GetString("format")
+	tmpl, _ := cmd.Flags().GetString("template")
+	switch {
+	case tmpl != "" && format != "" && format != formatTemplate:
+		exitWith(exitParse, "Error: --template can't be used with --format %s", format)
+	case tmpl != "":
+		return formatTemplate
+	case format == formatTemplate:
+		exitWith(exitParse, "Error: --format template needs a --template")
+	case format != "":
+		for _, f := range outputFormats {
+			if f == format {
+				return format
+			}
+		}
+		exitWith(exitParse, "Error: unknown format %q, use one of %s", format, strings.Join(outputFormats, ", "))
+	case jsonOutput:
+		return formatJSON
+	case !isTerminal(os.Stdout):
+		return formatTable
+	}
+	return ""
+}
+
+// printTodos prints todos to stdout in format.
+func printTodos(cmd *cobra.Command, todoList *model.TodoList, todos []model.Todo, format string) {
+	items := make([]model.ListItem, len(todos))
+	for i, todo := range todos {
+		items[i] = model.NewListItem(todoList, todo)
+	}
+	var err error
+	switch format {
+	case formatJSON:
+		err = writeJSON(os.Stdout, items)
+	case formatCSV:
+		err = writeCSV(os.Stdout, items)
+	case formatTSV:
+		err = writeTSV(os.Stdout, items)
+	case formatPlain:
+		err = writePlain(os.Stdout, items)
+	case formatTemplate:
+		tmpl, _ := cmd.Flags().GetString("template")
+		err = writeTemplate(os.Stdout, items, tmpl)
+	default:
+		printTable(todoList, todos, tableColumns)
+	}
+	handleErrorAndExit(err, "Error printing todos:")
+}
+
+// writeJSON writes items as an indented JSON array, [] when there are none.
+func writeJSON(w io.Writer, items []model.ListItem) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(items)
+}
+
+func writeCSV(w io.Writer, items []model.ListItem) error {
+	cw := csv.NewWriter(w)
+	cw.Write(model.ListItemFields)
+	for _, item := range items {
+		cw.Write(item.Record())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tsvEscaper keeps every TSV record on one line with its fields apart.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// writeTSV writes items as tab-separated values, with backslash escapes for
+// tabs, newlines and backslashes in the fields.
+func writeTSV(w io.Writer, items []model.ListItem) error {
+	if _, err := fmt.Fprintln(w, strings.Join(model.ListItemFields, "\t")); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record := item.Record()
+		for i, field := range record {
+			record[i] = tsvEscaper.Replace(field)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(record, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePlain writes one line per item: its ID, status and title.
+func writePlain(w io.Writer, items []model.ListItem) error {
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, "%d %s %s\n", item.ID, item.Status, item.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTemplate executes text, a text/template, for each item and ends each
+// output with a newline.
+func writeTemplate(w io.Writer, items []model.ListItem, text string) error {
+	tmpl, err := template.New("todo").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, item); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
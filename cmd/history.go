@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <title>",
+	Short: "Show how a todo changed over time",
+	Long: `Show every recorded change to a todo, oldest first: which field changed, from
+what to what, when and by whom. Changes made by undo and redo are recorded too.
+
+The user is $TOGO_USER, or else the name you are logged in as. History goes
+away with the todo when it is purged, see 'togo purge'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		if len(todoList.Todos) == 0 {
+			exitWith(exitNotFound, "No todos found. Add some todos with the 'add' command.")
+		}
+
+		selectedTodo := resolveTodoOrExit(todoList.Todos, args, "todos", selectTodoForHistory)
+		if len(selectedTodo.History) == 0 {
+			fmt.Printf("No history recorded for \"%s\"\n", selectedTodo.Title)
+			return
+		}
+		fmt.Printf("History of \"%s\":\n", selectedTodo.Title)
+		for _, change := range selectedTodo.History {
+			by := change.By
+			if by == "" {
+				by = "-"
+			}
+			fmt.Printf("  %s  %-10s %s\n", change.At.Local().Format("2006-01-02 15:04"), by, change)
+		}
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		todoList, err := loadTodoList()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterTitles(todoList.GetTodoTitles(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func selectTodoForHistory(todos []model.Todo) (model.Todo, error) {
+	return selectTodoPrompt("Select a todo to show the history of", todos)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import todos from a JSON export",
+	Long: `Import todos written by 'togo export', or another togo todos.json file.
+Use - to read from standard input. Todos are matched by UUID: ones already in
+your list are updated if the imported copy is newer, and the rest are added.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		handleErrorAndExit(err, "Error reading import:")
+
+		var doc model.Export
+		handleErrorAndExit(json.Unmarshal(data, &doc), "Error parsing import:")
+		handleErrorAndExit(model.CheckSchemaVersion(doc.SchemaVersion), "Error: "+args[0]+" was")
+
+		todoList := loadTodoListOrExit()
+		result := todoList.Import(doc)
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Imported %d todos: %d added, %d updated, %d unchanged\n",
+			len(doc.Todos), result.Added, result.Updated, result.Unchanged)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
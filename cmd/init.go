@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+// localDirIgnore keeps the copies togo writes next to the lists in a .togo
+// directory, before upgrades and on conflicts, out of version control. Their
+// backups, journals and locks are kept in the user's data directory.
+const localDirIgnore = `*.bak
+*.conflict
+`
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Start a todo list for the project in the current directory",
+	Long: `Create a .togo.json file in the current directory for the project's own todos.
+
+Whenever togo runs in this directory or below it, it finds the file the way git
+finds .git and uses it instead of your own lists, so a repository can carry its
+task list with it. Pass --global to use your own lists there anyway.
+
+With --dir a .togo directory is created instead, which can hold several lists.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetBool("dir")
+		wd, err := os.Getwd()
+		handleErrorAndExit(err, "Error:")
+		for _, name := range []string{model.LocalFileName, model.LocalDirName} {
+			if _, err := os.Stat(filepath.Join(wd, name)); err == nil {
+				exitWith(exitError, "Error: %s already exists", filepath.Join(wd, name))
+			}
+		}
+
+		path := filepath.Join(wd, model.LocalFileName)
+		if dir {
+			path = filepath.Join(wd, model.LocalDirName)
+			handleErrorAndExit(os.Mkdir(path, 0755), "Error creating project todos:")
+			handleErrorAndExit(os.WriteFile(filepath.Join(path, ".gitignore"), []byte(localDirIgnore), 0644), "Error creating project todos:")
+		}
+		useLocal(path)
+		store, err := openLocalStore(model.DefaultListName)
+		handleErrorAndExit(err, "Error creating project todos:")
+		handleErrorAndExit(store.Save(model.NewTodoList()), "Error creating project todos:")
+
+		fmt.Printf("Created %s\n", path)
+		fmt.Println("togo now uses it in this directory and below. Pass --global for your own lists.")
+		if !dir {
+			fmt.Printf("Consider adding %[1]s.conflict and %[1]s.*.bak to .gitignore\n", model.LocalFileName)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().Bool("dir", false, "Create a .togo directory, which can hold several lists")
+}
@@ -1,48 +1,121 @@
 package cmd
 
 import (
-	tea "github.com/charmbracelet/bubbletea"
+	"strings"
+
+	"github.com/prime-run/togo/model"
 	"github.com/prime-run/togo/ui"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [query]",
 	Short: "List all todos",
 	Long: `List all todos in a nice interactive UI.
 You can use:
+- list <query>: to show only todos matching a query, e.g. "status:pending +backend due:<3d"
+  (quote it when it has -tag terms, which would be read as flags otherwise)
 - list: to show active todos
 - list --archived: to show archived todos
-- list --all: to show both active and archived todos`,
+- list --all: to show both active and archived todos
+- list --tag <tag>: to show only todos with the given tag
+- list --project <name>: to show only todos in the given project and its sub-projects
+- list --completed-since <when>: to show todos completed since then, e.g. 1w or today
+- list --archived-since <when>: to show todos archived since then
+- list --modified-since <when>: to show todos changed since then
+
+When stdout is not a terminal, or with --format, the todos are printed instead:
+- list --format json: as a JSON array with every field, stable for scripts
+- list --format csv or --format tsv: as comma- or tab-separated values
+- list --format table: as a table aligned with spaces, the default when piped
+- list --format plain: one line per todo with its ID, status and title
+- list --template '{{.ID}} {{.Title}}': each with a Go template over the JSON fields`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		todoList := loadTodoListOrExit()
 
-		if checkEmptyTodoList(todoList, "No todos found. Add some todos with 'add' command.") {
+		format := outputFormat(cmd)
+		if format == "" && checkEmptyTodoList(todoList, "No todos found. Add some todos with 'add' command.") {
 			return
 		}
 
 		archivedFlag, _ := cmd.Flags().GetBool("archived")
 		allFlag, _ := cmd.Flags().GetBool("all")
+		tag, _ := cmd.Flags().GetString("tag")
+		project, _ := cmd.Flags().GetString("project")
 
 		m := ui.NewTodoTable(todoList)
+		queryText, _ := cmd.Flags().GetString("query")
+		queryText = strings.TrimSpace(strings.Join(append(args, queryText), " "))
+		var query *model.Query
+		if queryText != "" {
+			query = parseQueryOrExit(queryText)
+		}
 
 		if archivedFlag {
 			m.SetShowArchivedOnly(true)
-		} else if allFlag {
+		} else if allFlag || query != nil && query.Archived() {
 			m.SetShowAll(true)
 		} else {
 			m.SetShowActiveOnly(true)
 		}
+		if tag != "" {
+			m.SetTagFilter(tag)
+		}
+		if project != "" {
+			m.SetProjectFilter(project)
+		}
+		if query != nil {
+			m.SetQuery(query)
+		}
+		for _, filter := range timeFilterFlags {
+			value, _ := cmd.Flags().GetString(filter.flag)
+			if value == "" {
+				continue
+			}
+			since, err := model.ParsePastTime(value)
+			handleErrorAndExit(err, "Error parsing --"+filter.flag+":")
+			if filter.field == model.TimeArchived && !archivedFlag {
+				m.SetShowAll(true)
+			}
+			m.AddTimeFilter(filter.field, since, strings.ReplaceAll(filter.flag, "-", " ")+" "+value)
+		}
 
-		_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
-		handleErrorAndExit(err, "Error running program:")
-		saveTodoListOrExit(todoList)
+		if format != "" {
+			printTodos(cmd, todoList, m.VisibleTodos(), format)
+			return
+		}
+		runTodoTable(m)
 	},
 }
 
+// timeFilterFlags are the list flags that filter on when something happened.
+var timeFilterFlags = []struct {
+	flag  string
+	field model.TimeField
+	usage string
+}{
+	{"completed-since", model.TimeCompleted, "Show only todos completed since this time (e.g. 3d, 1w, today, 2024-01-15)"},
+	{"archived-since", model.TimeArchived, "Show only todos archived since this time (includes archived todos)"},
+	{"modified-since", model.TimeModified, "Show only todos changed since this time"},
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().BoolP("archived", "a", false, "Show only archived todos")
 	listCmd.Flags().Bool("all", false, "Show all todos (both active and archived)")
+	addTagFlag(listCmd, "Show only todos with this tag")
+	addQueryFlag(listCmd)
+	addFormatFlags(listCmd)
+	for _, filter := range timeFilterFlags {
+		listCmd.Flags().String(filter.flag, "", filter.usage)
+	}
+	listCmd.Flags().StringP("project", "P", "", "Show only todos in this project and its sub-projects")
+	listCmd.RegisterFlagCompletionFunc("project", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		todoList, err := loadTodoList()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterTitles(todoList.GetProjects(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
 }
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/prime-run/togo/model"
+	"github.com/prime-run/togo/ui"
+	"github.com/spf13/cobra"
+)
+
+var listsCmd = &cobra.Command{
+	Use:   "lists",
+	Short: "Show and manage todo lists",
+	Long: `Show your todo lists with the number of active todos in each.
+
+Todos live in the "todos" list unless you pick another one with --list or the
+TOGO_LIST environment variable, e.g. 'togo --list work add Write report'.
+Manage lists with the create, rename, delete and default subcommands.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := allListNames()
+		handleErrorAndExit(err, "Error reading lists:")
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		defaultName := defaultList()
+
+		width := 0
+		for _, name := range names {
+			width = max(width, len(name))
+		}
+		for _, name := range names {
+			marker := "  "
+			if name == ListName {
+				marker = "* "
+			}
+			count := "?"
+			if store, err := storeForList(backend, name); err == nil {
+				if todoList, err := store.Load(); err == nil {
+					count = fmt.Sprint(len(todoList.GetActiveTodos()))
+				}
+			}
+			line := fmt.Sprintf("%s%-*s  %s active", marker, width, name, count)
+			if name == defaultName {
+				line += " (default)"
+			}
+			fmt.Println(line)
+		}
+	},
+}
+
+var listsCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new todo list",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireListDir()
+		name := args[0]
+		handleErrorAndExit(model.ValidateListName(name), "Error:")
+		exists, err := listExists(name)
+		handleErrorAndExit(err, "Error reading lists:")
+		if exists {
+			exitWith(exitError, "Error: a list called \"%s\" already exists", name)
+		}
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		store, err := storeForList(backend, name)
+		handleErrorAndExit(err, "Error:")
+		todoList, err := store.Load()
+		handleErrorAndExit(err, "Error creating list:")
+		handleErrorAndExit(store.Save(todoList), "Error creating list:")
+		fmt.Printf("List \"%s\" created. Use it with 'togo --list %s' or TOGO_LIST=%s\n", name, name, name)
+	},
+}
+
+var listsRenameCmd = &cobra.Command{
+	Use:               "rename <old> <new>",
+	Short:             "Rename a todo list",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeListNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireListDir()
+		from, to := args[0], args[1]
+		handleErrorAndExit(model.RenameList(from, to), "Error renaming list:")
+		if fromDir, err := backupDir(from); err == nil {
+			if toDir, err := backupDir(to); err == nil {
+				os.Rename(fromDir, toDir)
+			}
+		}
+		if localPath != "" {
+			fromJournal, err := journalPath(nil, from)
+			handleErrorAndExit(err, "Error renaming list:")
+			toJournal, _ := journalPath(nil, to)
+			os.Rename(fromJournal, toJournal)
+		}
+		if defaultList() == from {
+			setDefaultListOrExit(to)
+		}
+		fmt.Printf("List \"%s\" renamed to \"%s\"\n", from, to)
+	},
+}
+
+var listsDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a todo list and all of its todos",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeListNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireListDir()
+		name := args[0]
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		confirmOrExit(fmt.Sprintf("Delete the list \"%s\" and all of its todos", name))
+		if store, err := storeForList(backend, name); err == nil {
+			if backups := findBackupStore(store); backups != nil && backups.Keep > 0 {
+				handleErrorAndExit(backups.Snapshot(), "Error backing up the list:")
+			}
+		}
+		handleErrorAndExit(model.DeleteList(name), "Error deleting list:")
+		if localPath != "" {
+			journal, err := journalPath(nil, name)
+			handleErrorAndExit(err, "Error deleting list:")
+			os.Remove(journal)
+		}
+		if defaultList() == name {
+			setDefaultListOrExit("")
+		}
+		fmt.Printf("List \"%s\" deleted\n", name)
+		if dir, err := backupDir(name); err == nil {
+			if backups, _ := model.ListBackups(dir); len(backups) > 0 {
+				fmt.Printf("Its backups were kept. To get it back, create it again and run 'togo --list %s restore'\n", name)
+			}
+		}
+	},
+}
+
+var listsDefaultCmd = &cobra.Command{
+	Use:               "default [name]",
+	Short:             "Show or set the list used when none is chosen",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeListNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		if localPath != "" {
+			exitWith(exitError, "Error: the default list of the project in %s is always \"%s\", use --global for your own lists", filepath.Dir(localPath), model.DefaultListName)
+		}
+		if len(args) == 0 {
+			fmt.Println(defaultList())
+			return
+		}
+		name := args[0]
+		exists, err := listExists(name)
+		handleErrorAndExit(err, "Error reading lists:")
+		if !exists {
+			exitWith(exitNotFound, "Error: no list called \"%s\"", name)
+		}
+		setDefaultListOrExit(name)
+		fmt.Printf("Default list set to \"%s\"\n", name)
+	},
+}
+
+// requireListDir exits when the project's todos are in a .togo.json file,
+// which holds a single list.
+func requireListDir() {
+	if file := localFile(); file != "" {
+		exitWith(exitError, "Error: %s holds a single list. Use a %s directory for more, or --global for your own lists", file, model.LocalDirName)
+	}
+}
+
+// allListNames returns every list, including the default list before
+// anything has been saved to it.
+func allListNames() ([]string, error) {
+	names, err := listNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if name == model.DefaultListName {
+			return names, nil
+		}
+	}
+	return append([]string{model.DefaultListName}, names...), nil
+}
+
+func setDefaultListOrExit(name string) {
+	config, err := loadConfig()
+	handleErrorAndExit(err, "Error reading config:")
+	if name == model.DefaultListName {
+		name = ""
+	}
+	config.DefaultList = name
+	handleErrorAndExit(saveConfig(config), "Error saving config:")
+}
+
+// runTodoTable runs the TUI with a list switcher and saves the list shown
+// when it quits, which is not the one it started with if the user switched.
+func runTodoTable(m ui.TodoTableModel) {
+	if names, err := allListNames(); err == nil {
+		m.SetListSwitcher(ListName, names, switchList)
+	}
+	final, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	handleErrorAndExit(err, "Error running program:")
+	saveTodoListOrExit(final.(ui.TodoTableModel).TodoList())
+}
+
+// switchList saves current to the current list and loads the list name,
+// which becomes the current list.
+func switchList(current *model.TodoList, name string) (*model.TodoList, error) {
+	store, err := todoStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Save(current); err != nil {
+		return nil, err
+	}
+	previous := ListName
+	ListName, Store = name, nil
+	todoList, err := loadTodoList()
+	if err != nil {
+		ListName, Store = previous, store
+		return nil, err
+	}
+	return todoList, nil
+}
+
+func completeListNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, _ := allListNames()
+	return filterTitles(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(listsCmd)
+	listsCmd.AddCommand(listsCreateCmd, listsRenameCmd, listsDeleteCmd, listsDefaultCmd)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:   "move <title> --to <list>",
+	Short: "Move a todo to another list",
+	Long: `Move a todo from the current list to another one, keeping its UUID,
+notes, tags and timestamps. Subtasks can move with it or stay behind, see
+--subtasks. Dependencies on todos that stay behind are dropped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		if to == ListName {
+			exitWith(exitError, "Error: the todo is already in the list \"%s\"", to)
+		}
+		exists, err := listExists(to)
+		handleErrorAndExit(err, "Error reading lists:")
+		if !exists {
+			exitWith(exitNotFound, "Error: no list called \"%s\", create it with 'togo lists create %s'", to, to)
+		}
+
+		todoList := loadTodoListOrExit()
+		if checkEmptyTodoList(todoList, "No todos found. Add some todos with the 'add' command.") {
+			return
+		}
+		selectedTodo := resolveTodoOrExit(todoList.Todos, args, "todos", func(todos []model.Todo) (model.Todo, error) {
+			return selectTodoPrompt("Select a todo to move", todos)
+		})
+		policy := childPolicyOrExit(cmd, todoList, selectedTodo, "move")
+
+		todos := []model.Todo{selectedTodo}
+		if policy == model.IncludeChildren {
+			for _, id := range todoList.GetDescendantIDs(selectedTodo.ID) {
+				todos = append(todos, *todoList.GetTodoByID(id))
+			}
+		}
+
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		target, err := storeForList(backend, to)
+		handleErrorAndExit(err, "Error:")
+		targetList, err := target.Load()
+		handleErrorAndExit(err, fmt.Sprintf("Error loading list \"%s\":", to))
+		targetList.Import(model.Export{SchemaVersion: model.SchemaVersion, Todos: todos})
+		handleErrorAndExit(target.Save(targetList), fmt.Sprintf("Error saving list \"%s\":", to))
+
+		todoList.DeleteWithChildren(selectedTodo.ID, policy)
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Todo \"%s\" moved to \"%s\"\n", selectedTodo.Title, to)
+		if len(todos) > 1 {
+			fmt.Printf("%d subtasks moved with it\n", len(todos)-1)
+		}
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		todoList, err := loadTodoList()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterTitles(todoList.GetTodoTitles(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+	moveCmd.Flags().String("to", "", "List to move the todo to")
+	moveCmd.MarkFlagRequired("to")
+	moveCmd.RegisterFlagCompletionFunc("to", completeListNames)
+	addSubtasksFlag(moveCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/prime-run/togo/ui"
+	"github.com/spf13/cobra"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note <title>",
+	Short: "Edit the notes of a todo",
+	Long: `Open the notes of a todo in your editor ($VISUAL or $EDITOR) and save them
+back when the editor exits. Use notes for context such as repro steps or links.
+With --print the notes are written to stdout instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		if len(todoList.Todos) == 0 {
+			exitWith(exitNotFound, "No todos found. Add some todos with the 'add' command.")
+		}
+
+		selectedTodo := resolveTodoOrExit(todoList.Todos, args, "todos", selectTodoForNote)
+
+		if printFlag, _ := cmd.Flags().GetBool("print"); printFlag {
+			if selectedTodo.Notes != "" {
+				fmt.Println(selectedTodo.Notes)
+			}
+			return
+		}
+
+		notes, err := ui.EditNotes(selectedTodo.Notes)
+		handleErrorAndExit(err, "Error running editor:")
+		if notes == selectedTodo.Notes {
+			fmt.Printf("Notes for \"%s\" unchanged\n", selectedTodo.Title)
+			return
+		}
+		todoList.SetNotes(selectedTodo.ID, notes)
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Notes for \"%s\" saved\n", selectedTodo.Title)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		todoList, err := loadTodoList()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return filterTitles(todoList.GetTodoTitles(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func selectTodoForNote(todos []model.Todo) (model.Todo, error) {
+	return selectTodoPrompt("Select a todo to edit notes for", todos)
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+	noteCmd.Flags().Bool("print", false, "Print the notes instead of opening the editor")
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "Show the project tree",
+	Long: `Show every project as a tree with the number of pending and completed
+todos in each one. Counts include sub-projects, and archived todos are left out.
+Assign a project when adding a todo with 'togo add <title> project:work.api'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+
+		tree := todoList.ProjectTree()
+		if len(tree) == 0 {
+			fmt.Println("No projects found. Add one with 'togo add <title> project:<name>'.")
+			return
+		}
+
+		var labels, counts []string
+		var walk func(nodes []*model.ProjectNode, indent string, top bool)
+		walk = func(nodes []*model.ProjectNode, indent string, top bool) {
+			for i, node := range nodes {
+				branch, childIndent := "├── ", "│   "
+				if i == len(nodes)-1 {
+					branch, childIndent = "└── ", "    "
+				}
+				if top {
+					branch, childIndent = "", ""
+				}
+				labels = append(labels, indent+branch+node.Name)
+				counts = append(counts, fmt.Sprintf("%d pending, %d completed", node.Pending, node.Completed))
+				walk(node.Children, indent+childIndent, false)
+			}
+		}
+		walk(tree, "", true)
+
+		width := 0
+		for _, label := range labels {
+			if n := len([]rune(label)); n > width {
+				width = n
+			}
+		}
+		for i, label := range labels {
+			padding := strings.Repeat(" ", width-len([]rune(label))+2)
+			fmt.Println(label + padding + counts[i])
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(projectsCmd)
+}
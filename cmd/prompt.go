@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
+)
+
+// assumeYes is set by --yes: confirmations are answered yes without asking.
+var assumeYes bool
+
+// noInput is set by --no-input: togo never prompts, as when it runs without
+// a terminal.
+var noInput bool
+
+// interactive reports whether togo may prompt: not with --no-input, and only
+// when both the keyboard and the prompt's output are a terminal.
+func interactive() bool {
+	if noInput {
+		return false
+	}
+	out := os.Stdout
+	if jsonOutput {
+		out = os.Stderr
+	}
+	return isTerminal(os.Stdin) && isTerminal(out)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// promptOutput is where prompts are drawn, out of the way of --json results.
+func promptOutput() io.WriteCloser {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return nil
+}
+
+// confirmOrExit asks the user to confirm label, and exits when they don't or
+// can't be asked. --yes confirms without asking.
+func confirmOrExit(label string) {
+	if assumeYes {
+		return
+	}
+	if !interactive() {
+		exitWith(exitCancelled, "Error: %s? Use --yes to confirm without a prompt", label)
+	}
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+		Stdout:    promptOutput(),
+	}
+	if _, err := prompt.Run(); err != nil {
+		exitWith(exitCancelled, "Operation cancelled")
+	}
+}
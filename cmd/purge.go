@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete archived todos and their history",
+	Long: `Permanently delete archived todos, along with their history and their
+entries in the undo journal, so they can't be brought back by undo. Use
+--before to only purge todos archived a while ago, e.g. --before 30d.
+
+--history also drops the history recorded before the given time from the
+todos that are kept, e.g. --history 90d, and from the copies of them in the
+undo journal and the backups. Purged todos stay in the backups, so that
+'togo restore' can still bring them back. --yes purges without asking.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		beforeFlag, _ := cmd.Flags().GetString("before")
+		historyFlag, _ := cmd.Flags().GetString("history")
+		before := time.Now()
+		if beforeFlag != "" {
+			var err error
+			before, err = model.ParsePastTime(beforeFlag)
+			handleErrorAndExit(err, "Error:")
+		}
+		var historyBefore time.Time
+		if historyFlag != "" {
+			var err error
+			historyBefore, err = model.ParsePastTime(historyFlag)
+			handleErrorAndExit(err, "Error:")
+		}
+
+		todoList := loadTodoListOrExit()
+		candidates := todoList.Purgeable(before)
+		if len(candidates) == 0 && historyBefore.IsZero() {
+			fmt.Println("No archived todos to purge")
+			return
+		}
+
+		if len(candidates) > 0 {
+			printDiffTodos("purge", "-", candidates)
+			confirmOrExit("Delete them for good")
+		}
+
+		purged := todoList.Purge(before)
+		dropped := 0
+		if !historyBefore.IsZero() {
+			dropped = todoList.CompactHistory(historyBefore)
+		}
+		saveTodoListOrExit(todoList)
+
+		uuids := make([]string, len(purged))
+		for i, todo := range purged {
+			uuids[i] = todo.UUID
+		}
+		if store, err := todoStore(); err == nil {
+			if journal, ok := store.(*model.JournalStore); ok {
+				handleErrorAndExit(journal.Forget(uuids), "Purged, but could not compact the journal:")
+				if !historyBefore.IsZero() {
+					handleErrorAndExit(journal.CompactHistory(historyBefore), "Purged, but could not compact the journal:")
+				}
+			}
+			if backups := findBackupStore(store); backups != nil && !historyBefore.IsZero() {
+				handleErrorAndExit(backups.CompactHistory(historyBefore), "Purged, but could not compact the backups:")
+			}
+		}
+
+		fmt.Printf("Purged %s\n", countTodos(len(purged)))
+		if !historyBefore.IsZero() {
+			fmt.Printf("Dropped %d history entries\n", dropped)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+	purgeCmd.Flags().String("before", "", "Only purge todos archived before this time (e.g. 30d, 2024-01-15)")
+	purgeCmd.Flags().String("history", "", "Also drop history recorded before this time from the todos kept")
+}
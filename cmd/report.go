@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/prime-run/togo/model"
+	"github.com/prime-run/togo/ui"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [name]",
+	Short: "Run a saved report, or list the reports",
+	Long: `Run a report: a saved query with a sort order, columns and a limit, printed
+as a table or, with --tui, opened in the interactive UI. Without a name the
+reports are listed.
+
+togo comes with the reports next, overdue, recent and waiting. Define your
+own, or override these, under "reports" in config.json:
+
+  "reports": {
+    "backend": {
+      "description": "My backend queue",
+      "filter": "status:pending +backend",
+      "sort": ["-priority", "due"],
+      "columns": ["id", "priority", "due", "title"],
+      "limit": 20
+    }
+  }
+
+Sort by any column, prefixed with - for descending order. The columns are
+` + strings.Join(model.Columns, ", ") + `.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reports, err := allReports()
+		handleErrorAndExit(err, "Error:")
+		if len(args) == 0 {
+			printReports(reports)
+			return
+		}
+		report, ok := reports[args[0]]
+		if !ok {
+			exitWith(exitNotFound, "Error: no report called %q, see 'togo report' for the reports", args[0])
+		}
+		if err := report.Validate(); err != nil {
+			exitWith(exitParse, "Error in report %q: %v", args[0], err)
+		}
+
+		todoList := loadTodoListOrExit()
+		if tui, _ := cmd.Flags().GetBool("tui"); tui {
+			query := parseQueryOrExit(report.Filter)
+			m := ui.NewTodoTable(todoList)
+			if query.Archived() {
+				m.SetShowAll(true)
+			} else {
+				m.SetShowActiveOnly(true)
+			}
+			m.SetQuery(query)
+			m.SetSort(report.Sort)
+			m.SetColumns(report.ReportColumns())
+			m.SetLimit(report.Limit)
+			runTodoTable(m)
+			return
+		}
+
+		todos, err := report.Run(todoList)
+		handleErrorAndExit(err, "Error:")
+		if len(todos) == 0 {
+			fmt.Printf("No todos in the report %s\n", args[0])
+			return
+		}
+		printTable(todoList, todos, report.ReportColumns())
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		reports, _ := allReports()
+		var names []string
+		for name := range reports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return filterTitles(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+// allReports returns the built-in reports along with those in the config
+// file, which take precedence.
+func allReports() (map[string]model.Report, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[string]model.Report, len(model.BuiltinReports)+len(config.Reports))
+	for name, report := range model.BuiltinReports {
+		reports[name] = report
+	}
+	for name, report := range config.Reports {
+		reports[name] = report
+	}
+	return reports, nil
+}
+
+func printReports(reports map[string]model.Report) {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		description := reports[name].Description
+		if description == "" {
+			description = reports[name].Filter
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, description)
+	}
+	w.Flush()
+}
+
+// printTable prints the columns of todos as a table aligned with spaces,
+// under a header.
+func printTable(todoList *model.TodoList, todos []model.Todo, columns []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = strings.ToUpper(column)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, todo := range todos {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = model.ColumnValue(todoList, todo, column)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().Bool("tui", false, "Open the report in the interactive UI")
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prime-run/togo/model"
+)
+
+// Exit codes of togo. Anything not covered by a more specific code, such as
+// completing a blocked todo, exits with exitError.
+const (
+	exitError     = 1
+	exitParse     = 2 // a deadline, priority, query or flag value can't be parsed
+	exitNotFound  = 3 // no todo matches
+	exitAmbiguous = 4 // several todos match and togo can't ask which one
+	exitCancelled = 5 // a prompt was cancelled, declined or can't be shown
+	exitIO        = 6 // todos can't be loaded or saved
+)
+
+// errorCodes name the exit codes in --json results.
+var errorCodes = map[int]string{
+	exitError:     "error",
+	exitParse:     "parse_error",
+	exitNotFound:  "not_found",
+	exitAmbiguous: "ambiguous",
+	exitCancelled: "cancelled",
+	exitIO:        "io_error",
+}
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  any other error, e.g. completing a blocked todo without --force
+  2  a deadline, priority, query or flag value can't be parsed
+  3  no todo matches
+  4  several todos match and togo can't ask which one was meant
+  5  a prompt was cancelled or declined, or needed without a terminal
+  6  todos can't be loaded or saved`
+
+// jsonOutput is set by --json: commands print a result for scripts instead
+// of sentences, and never ask which todo was meant.
+var jsonOutput bool
+
+// action is the name of the command that is running, reported in results.
+var action string
+
+// result is what add, toggle, archive, unarchive and delete print with
+// --json. Like model.ListItem its fields are always present.
+type result struct {
+	Action string           `json:"action"`
+	OK     bool             `json:"ok"`
+	Todos  []model.ListItem `json:"todos"`
+	Error  *resultError     `json:"error"`
+}
+
+type resultError struct {
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+	// Matches are the todos an ambiguous title matched.
+	Matches []match `json:"matches"`
+}
+
+// match is enough of a todo to tell it apart from the others that matched.
+type match struct {
+	ID    int    `json:"id"`
+	UUID  string `json:"uuid"`
+	Title string `json:"title"`
+}
+
+// messageOutput is where notices and warnings are printed: stdout, or
+// stderr with --json so that stdout only carries the result.
+func messageOutput() io.Writer {
+	if jsonOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// printResult prints the todos an action changed as a --json result.
+func printResult(todoList *model.TodoList, todos []model.Todo) {
+	items := make([]model.ListItem, len(todos))
+	for i, todo := range todos {
+		items[i] = model.NewListItem(todoList, todo)
+	}
+	writeResult(os.Stdout, result{Action: action, OK: true, Todos: items})
+}
+
+func writeResult(w io.Writer, r result) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(r)
+}
+
+// exitWith prints an error message, as a --json result when asked to, and
+// exits with code.
+func exitWith(code int, format string, args ...any) {
+	exitWithMatches(code, nil, format, args...)
+}
+
+// exitWithMatches is exitWith for an ambiguous match, listing the todos that
+// matched.
+func exitWithMatches(code int, matches []model.Todo, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if !jsonOutput {
+		fmt.Println(message)
+		for _, todo := range matches {
+			fmt.Printf("  %d  %s\n", todo.ID, todo.Title)
+		}
+		os.Exit(code)
+	}
+	e := &resultError{
+		Code:     errorCodes[code],
+		ExitCode: code,
+		Message:  strings.TrimPrefix(message, "Error: "),
+		Matches:  []match{},
+	}
+	for _, todo := range matches {
+		e.Matches = append(e.Matches, match{todo.ID, todo.UUID, todo.Title})
+	}
+	writeResult(os.Stdout, result{Action: action, Todos: []model.ListItem{}, Error: e})
+	os.Exit(code)
+}
+
+// todosByID returns the todos of todoList with the given IDs, in that order.
+func todosByID(todoList *model.TodoList, ids []int) []model.Todo {
+	var todos []model.Todo
+	for _, id := range ids {
+		if todo := todoList.GetTodoByID(id); todo != nil {
+			todos = append(todos, *todo)
+		}
+	}
+	return todos
+}
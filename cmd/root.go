@@ -1,18 +1,32 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/prime-run/togo/model"
 	"github.com/prime-run/togo/ui"
 
-	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
-var TodoFileName = "todos.json"
+// ListName is the list commands work on, chosen with --list or TOGO_LIST.
+var ListName = model.DefaultListName
 
 var rootCmd = &cobra.Command{
 	Use:   "togo",
 	Short: "A simple todo application",
-	Long:  `A simple todo application that lets you manage your tasks from the terminal.`,
+	Long: `A simple todo application that lets you manage your tasks from the terminal.
+
+` + exitCodesHelp,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		jsonOutput, _ = cmd.Flags().GetBool("json")
+		assumeYes, _ = cmd.Flags().GetBool("yes")
+		noInput, _ = cmd.Flags().GetBool("no-input")
+		action = cmd.Name()
+		setUpDataDir(cmd)
+		selectList(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		todoList := loadTodoListOrExit()
 
@@ -20,11 +34,7 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
-		tableModel := ui.NewTodoTable(todoList)
-		_, err := tea.NewProgram(tableModel, tea.WithAltScreen()).Run()
-		handleErrorAndExit(err, "Error running program:")
-
-		saveTodoListOrExit(todoList)
+		runTodoTable(ui.NewTodoTable(todoList))
 	},
 }
 
@@ -32,12 +42,71 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// setUpDataDir applies --data-dir, otherwise looks for the todos of the
+// project the working directory is in unless --global is given and, when the
+// default data directory is used, moves the data of older versions out of the
+// cache directory.
+func setUpDataDir(cmd *cobra.Command) {
+	if dataDir, _ := cmd.Flags().GetString("data-dir"); dataDir != "" {
+		model.SetDataDir(dataDir)
+		return
+	}
+	if global, _ := cmd.Flags().GetBool("global"); !global {
+		if wd, err := os.Getwd(); err == nil {
+			path, err := model.FindLocal(wd)
+			handleErrorAndExit(err, "Error looking for project todos:")
+			if path != "" {
+				useLocal(path)
+				return
+			}
+		}
+	}
+	if os.Getenv("TOGO_DATA_DIR") != "" {
+		return
+	}
+	movedFrom, err := model.MigrateLegacyDataDir()
+	if err != nil {
+		fmt.Fprintln(messageOutput(), "Warning: could not move your todos out of the cache directory:", err)
+		return
+	}
+	if movedFrom != "" {
+		dataDir, _ := model.DataDir()
+		fmt.Fprintf(messageOutput(), "Moved your todos from %s to %s\n", movedFrom, dataDir)
+	}
+}
+
+// selectList picks the list to work on from --list, TOGO_LIST or the
+// configured default list. The default list of a project is always "todos".
+func selectList(cmd *cobra.Command) {
+	name, _ := cmd.Flags().GetString("list")
+	if name == "" {
+		name = os.Getenv("TOGO_LIST")
+	}
+	if name == "" && localPath == "" {
+		name = defaultList()
+	}
+	if name == "" {
+		name = model.DefaultListName
+	}
+	handleErrorAndExit(model.ValidateListName(name), "Error:")
+	ListName = name
+}
+
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.todooo.yaml)")
+	rootCmd.PersistentFlags().String("list", "", "Todo list to use (default $TOGO_LIST or the default list)")
+	rootCmd.RegisterFlagCompletionFunc("list", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		names, _ := listNames()
+		return filterTitles(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.PersistentFlags().String("data-dir", "", "Directory to keep todos in (default $TOGO_DATA_DIR or $XDG_DATA_HOME/togo)")
+	rootCmd.PersistentFlags().Bool("global", false, "Use your own todos even inside a project with a .togo.json file or .togo directory")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Answer yes to confirmations without asking, and archive or delete subtasks too")
+	rootCmd.PersistentFlags().Bool("no-input", false, "Never prompt: fail on ambiguous matches and unconfirmed changes (the default without a terminal)")
+	rootCmd.PersistentFlags().Bool("json", false, "Print results and errors as JSON for scripts (add, toggle, archive, unarchive, delete and list)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Show or change where todos are stored",
+	Long: `Show which storage backend holds your todos and where.
+
+Todos are kept in a JSON file by default. For large lists, 'togo storage
+migrate --to sqlite' moves them into an embedded SQLite database, which only
+writes the todos that changed on each save.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		backend, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		store, err := storeFor(backend)
+		handleErrorAndExit(err, "Error:")
+		fmt.Printf("Backend:  %s\n", backend)
+		fmt.Printf("Location: %s\n", storePath(store))
+		if localPath != "" {
+			fmt.Println("(project todos, use --global for your own)")
+		} else if os.Getenv("TOGO_STORAGE") != "" {
+			fmt.Println("(set by TOGO_STORAGE)")
+		}
+	},
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate --to <json|sqlite>",
+	Short: "Move todos to another storage backend",
+	Long: `Copy every list into another storage backend and switch to it.
+
+The copy is read back and compared with the original before the switch, and
+the old storage is left in place, so nothing is lost if anything goes wrong.
+Data left behind by an earlier migration to the same backend is renamed out
+of the way, to <file>.<time>.old, so you can migrate back and forth.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if localPath != "" {
+			exitWith(exitError, "Error: project todos in %s are always stored as JSON, use --global to migrate your own", localPath)
+		}
+		to, _ := cmd.Flags().GetString("to")
+		to = strings.ToLower(to)
+		_, err := storageExt(to)
+		handleErrorAndExit(err, "Error:")
+		from, err := storageBackend()
+		handleErrorAndExit(err, "Error reading config:")
+		from = strings.ToLower(from)
+		if to == from {
+			fmt.Printf("Todos are already stored in %s.\n", to)
+			return
+		}
+		fromExt, err := storageExt(from)
+		handleErrorAndExit(err, "Error:")
+		names, err := model.ListNames(fromExt)
+		handleErrorAndExit(err, "Error reading lists:")
+		if len(names) == 0 {
+			names = []string{model.DefaultListName}
+		}
+		var sources []model.Store
+		var target model.Store
+		for _, name := range names {
+			source, err := storeForList(from, name)
+			handleErrorAndExit(err, "Error:")
+			target, err = storeForList(to, name)
+			handleErrorAndExit(err, "Error:")
+			stale, err := target.Load()
+			handleErrorAndExit(err, fmt.Sprintf("Error reading %s:", storePath(target)))
+			if len(stale.Todos) > 0 {
+				moved, err := moveAside(storePath(target))
+				handleErrorAndExit(err, "Error moving the data of an earlier migration aside:")
+				fmt.Printf("Moved %d todos left by an earlier migration to %s\n", len(stale.Todos), moved)
+			}
+			count, err := model.CopyStore(source, target)
+			handleErrorAndExit(err, fmt.Sprintf("Error migrating to %s:", storePath(target)))
+			fmt.Printf("Migrated %d todos in list %q from %s to %s\n", count, name, from, to)
+			sources = append(sources, source)
+		}
+
+		config, err := loadConfig()
+		handleErrorAndExit(err, "Error reading config:")
+		config.Storage = to
+		handleErrorAndExit(saveConfig(config), "Error saving config:")
+
+		fmt.Printf("Todos are now stored in %s\n", filepath.Dir(storePath(target)))
+		for _, source := range sources {
+			fmt.Printf("The old data was left in %s\n", storePath(source))
+		}
+		if env := os.Getenv("TOGO_STORAGE"); env != "" && env != to {
+			fmt.Printf("Note: TOGO_STORAGE=%s still overrides the config\n", env)
+		}
+	},
+}
+
+// moveAside renames the data file at path out of the way, along with its
+// journal and SQLite's -wal and -shm files, and returns its new path.
+func moveAside(path string) (string, error) {
+	suffix := "." + time.Now().Format("20060102-150405") + ".old"
+	for _, extra := range []string{".journal", "-wal", "-shm"} {
+		if err := os.Rename(path+extra, path+suffix+extra); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if err := os.Rename(path, path+suffix); err != nil {
+		return "", err
+	}
+	return path + suffix, nil
+}
+
+// storePath describes where store keeps its data.
+func storePath(store model.Store) string {
+	switch s := store.(type) {
+	case *model.JournalStore:
+		return storePath(s.Store)
+	case *model.BackupStore:
+		return storePath(s.Store)
+	case *model.JSONFileStore:
+		return s.Path
+	case *model.SQLiteStore:
+		return s.Path
+	}
+	return "memory"
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageMigrateCmd)
+	storageMigrateCmd.Flags().String("to", "", "Backend to move todos to: 'json' or 'sqlite'")
+	storageMigrateCmd.MarkFlagRequired("to")
+	storageMigrateCmd.RegisterFlagCompletionFunc("to", cobra.FixedCompletions([]string{storageJSON, storageSQLite}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -2,193 +2,99 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/manifoldco/promptui"
 	"github.com/prime-run/togo/model"
 	"github.com/spf13/cobra"
-	"os"
-	"strconv"
-	"strings"
+	"slices"
 )
 
 var toggleCmd = &cobra.Command{
 	Use:   "toggle <title>",
 	Short: "Toggle todo completion status",
-	Long:  `Toggle the completion status of a todo. It marks a pending todo as completed and vice versa.`,
+	Long: `Toggle the completion status of a todo. It marks a pending todo as completed and vice versa.
+Todos blocked by unfinished dependencies are only completed with --force.
+With --all-matches every todo matching the title, --tag and --query is toggled.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		todoList, err := model.LoadTodoList(TodoFileName)
-		if err != nil {
-			fmt.Println("Error loading todos:", err)
-			os.Exit(1)
-		}
+		todoList := loadTodoListOrExit()
 		if len(todoList.Todos) == 0 {
-			fmt.Println("No todos found. Add some todos with the 'add' command.")
-			os.Exit(1)
+			exitWith(exitNotFound, "No todos found. Add some todos with the 'add' command.")
+		}
+		tag, _ := cmd.Flags().GetString("tag")
+		candidates := model.FilterByTag(todoList.Todos, tag)
+		if len(candidates) == 0 {
+			exitWith(exitNotFound, "No todos tagged +%s found.", model.NormalizeTag(tag))
 		}
-		if len(args) > 0 {
-			todoTitle := args[0]
-			todo, found := todoList.FindByTitle(todoTitle, false)
-			if found {
-				todoList.Toggle(todo.ID)
-				status := "Pending"
-				for _, t := range todoList.Todos {
-					if t.ID == todo.ID {
-						if t.Completed {
-							status = "Completed"
-						}
-						break
-					}
-				}
-				if err := todoList.Save(TodoFileName); err != nil {
-					fmt.Println("Error saving todos:", err)
-					os.Exit(1)
-				}
-				fmt.Printf("Todo \"%s\" toggled successfully\n", todoTitle)
-				fmt.Printf("Status: %s\n", status)
-				return
-			}
-			id, err := strconv.Atoi(todoTitle)
-			if err == nil {
-				for _, todo := range todoList.Todos {
-					if todo.ID == id {
-						todoList.Toggle(id)
-						var status string
-						if !todo.Completed {
-							status = "Completed"
-						} else {
-							status = "Pending"
-						}
-						if err := todoList.Save(TodoFileName); err != nil {
-							fmt.Println("Error saving todos:", err)
-							os.Exit(1)
-						}
-						fmt.Printf("Todo \"%s\" toggled successfully\n", todo.Title)
-						fmt.Printf("Status: %s\n", status)
-						return
-					}
-				}
-			}
-
-			var matches []model.Todo
 
-			for _, todo := range todoList.Todos {
-				if strings.Contains(strings.ToLower(todo.Title), strings.ToLower(todoTitle)) {
-					matches = append(matches, todo)
+		candidates = filterByQueryOrExit(cmd, todoList, candidates, "todos")
+		selected := resolveTodosOrExit(cmd, candidates, args, "todos", selectTodoForToggle)
+		force, _ := cmd.Flags().GetBool("force")
+		for _, selectedTodo := range selected {
+			if blockers := todoList.Blockers(selectedTodo.ID); !selectedTodo.Completed && len(blockers) > 0 {
+				if !force {
+					exitWith(exitError, "Error: \"%s\" is blocked by %s\nComplete those first, or use --force to complete it anyway.", selectedTodo.Title, model.FormatTitles(blockers))
 				}
+				fmt.Fprintf(messageOutput(), "Warning: completing \"%s\" while it is blocked by %s\n", selectedTodo.Title, model.FormatTitles(blockers))
 			}
+		}
 
-			if len(matches) == 0 {
-				fmt.Printf("Error: No todos found matching \"%s\"\n", todoTitle)
-				os.Exit(1)
-			} else if len(matches) == 1 {
-				todoList.Toggle(matches[0].ID)
-				status := "Completed"
-
-				if matches[0].Completed {
-					status = "Pending"
-				}
-
-				if err := todoList.Save(TodoFileName); err != nil {
-					fmt.Println("Error saving todos:", err)
-					os.Exit(1)
-				}
-				fmt.Printf("Todo \"%s\" toggled successfully\n", matches[0].Title)
-				fmt.Printf("Status: %s\n", status)
-				return
-
-			} else {
-				selectedTodo, err := selectTodoForToggle(matches)
+		// The result has the todos, their next occurrences and their parents
+		// when toggling changed them
+		var ids []int
+		var lines []string
+		for _, selectedTodo := range selected {
+			parent := todoList.GetTodoByID(selectedTodo.ParentID)
+			parentCompleted := parent != nil && parent.Completed
+			todoList.ForceToggle(selectedTodo.ID)
+			ids = append(ids, selectedTodo.ID)
 
-				if err != nil {
-					fmt.Println("Operation cancelled")
-					os.Exit(0)
+			status := "Pending"
+			if todoList.GetTodoByID(selectedTodo.ID).Completed {
+				status = "Completed"
+			}
+			lines = append(lines, fmt.Sprintf("Todo \"%s\" toggled successfully", selectedTodo.Title), "Status: "+status)
+			if next := todoList.GetNextOccurrence(selectedTodo.ID); next != nil && status == "Completed" {
+				ids = append(ids, next.ID)
+				lines = append(lines, fmt.Sprintf("Next occurrence added with ID %d, due %s", next.ID, next.Deadline.Format("2006-01-02 15:04")))
+			}
+			if parent = todoList.GetTodoByID(selectedTodo.ParentID); parent != nil && parent.Completed != parentCompleted {
+				if !slices.Contains(ids, parent.ID) {
+					ids = append(ids, parent.ID)
 				}
-				todoList.Toggle(selectedTodo.ID)
-				var status string
-
-				if !selectedTodo.Completed {
-					status = "Completed"
+				if parent.Completed {
+					lines = append(lines, fmt.Sprintf("All subtasks done, \"%s\" completed too", parent.Title))
 				} else {
-					status = "Pending"
-				}
-
-				if err := todoList.Save(TodoFileName); err != nil {
-					fmt.Println("Error saving todos:", err)
-					os.Exit(1)
+					lines = append(lines, fmt.Sprintf("\"%s\" reopened", parent.Title))
 				}
-				fmt.Printf("Todo \"%s\" toggled successfully\n", selectedTodo.Title)
-				fmt.Printf("Status: %s\n", status)
-
-				return
-			}
-
-		} else {
-			todos := todoList.Todos
-			if len(todos) == 0 {
-				fmt.Println("No todos found. Add some todos with the 'add' command.")
-				os.Exit(0)
-			}
-			selectedTodo, err := selectTodoForToggle(todos)
-			if err != nil {
-				fmt.Println("Operation cancelled")
-				os.Exit(0)
 			}
-			todoList.Toggle(selectedTodo.ID)
-			var status string
-			if !selectedTodo.Completed {
-				status = "Completed"
-			} else {
-				status = "Pending"
-			}
-			if err := todoList.Save(TodoFileName); err != nil {
-				fmt.Println("Error saving todos:", err)
-				os.Exit(1)
-			}
-			fmt.Printf("Todo \"%s\" toggled successfully\n", selectedTodo.Title)
-			fmt.Printf("Status: %s\n", status)
+		}
+		saveTodoListOrExit(todoList)
+		if jsonOutput {
+			printResult(todoList, todosByID(todoList, ids))
+			return
+		}
+		for _, line := range lines {
+			fmt.Println(line)
 		}
 	},
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) != 0 {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
-		todoList, err := model.LoadTodoList(TodoFileName)
+		todoList, err := loadTodoList()
 		if err != nil {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
-		titles := todoList.GetTodoTitles()
-		if toComplete != "" {
-			var filtered []string
-			for _, title := range titles {
-				if strings.Contains(strings.ToLower(title), strings.ToLower(toComplete)) {
-					filtered = append(filtered, title)
-				}
-			}
-			return filtered, cobra.ShellCompDirectiveNoFileComp
-		}
-		return titles, cobra.ShellCompDirectiveNoFileComp
+		return filterTitles(todoList.GetTodoTitles(), toComplete), cobra.ShellCompDirectiveNoFileComp
 	},
 }
 
 func selectTodoForToggle(todos []model.Todo) (model.Todo, error) {
-	templates := &promptui.SelectTemplates{
-		Label:    "{{ . }}",
-		Active:   "▶ {{ .Title | cyan }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-		Inactive: "  {{ .Title }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-		Selected: "✓ {{ .Title | green }} {{ if .Completed }}(Completed){{ else }}(Pending){{ end }}",
-	}
-	prompt := promptui.Select{
-		Label:     "Select a todo to toggle status",
-		Items:     todos,
-		Templates: templates,
-		Size:      10,
-	}
-	index, _, err := prompt.Run()
-	if err != nil {
-		return model.Todo{}, err
-	}
-	return todos[index], nil
+	return selectTodoPrompt("Select a todo to toggle status", todos)
 }
 
 func init() {
 	rootCmd.AddCommand(toggleCmd)
+	addTagFlag(toggleCmd, "Only consider todos with this tag")
+	addQueryFlag(toggleCmd)
+	addAllMatchesFlag(toggleCmd)
+	toggleCmd.Flags().BoolP("force", "f", false, "Complete the todo even if it is blocked")
 }
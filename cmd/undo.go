@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/prime-run/togo/model"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last change to your todos",
+	Long: `Undo the last change made to the current list, by a command or in the TUI.
+
+Every change is recorded in a journal next to the list, so undo can be run
+again to step further back, up to 100 changes. A bulk action in the TUI is
+undone as a whole. 'togo redo' makes an undone change again.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		op, err := todoList.Undo()
+		if err == model.ErrNothingToUndo {
+			fmt.Println("Nothing to undo")
+			return
+		}
+		handleErrorAndExit(err, "Error:")
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Undid %s\n", op.Action)
+	},
+}
+
+var redoCmd = &cobra.Command{
+	Use:   "redo",
+	Short: "Redo the last change undone with togo undo",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		todoList := loadTodoListOrExit()
+		op, err := todoList.Redo()
+		if err == model.ErrNothingToRedo {
+			fmt.Println("Nothing to redo")
+			return
+		}
+		handleErrorAndExit(err, "Error:")
+		saveTodoListOrExit(todoList)
+		fmt.Printf("Redid %s\n", op.Action)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd, redoCmd)
+}
@@ -29,9 +29,6 @@ import (
 )
 
 func main() {
-	if dir, err := os.Getwd(); err == nil {
-		os.Chdir(dir)
-	}
 	if err := cmd.Execute(); err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
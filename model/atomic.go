@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockTimeout is how long Save waits for another togo process to finish
+// saving before giving up.
+const lockTimeout = 10 * time.Second
+
+// writeFileAtomic replaces path with data so that a crash leaves either the
+// old or the new contents, never a mix: the data goes to a temporary file in
+// the same directory, is synced to disk and then renamed over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a directory entry change such as a rename to disk. Not
+// every platform supports syncing directories, so this is best effort.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+// acquireLock takes the advisory lock file at path, waiting up to
+// lockTimeout for another togo process to release it. The returned function
+// releases the lock.
+func acquireLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err = tryLock(f)
+		if err == nil {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, errLocked) || time.Now().After(deadline) {
+			f.Close()
+			if errors.Is(err, errLocked) {
+				return nil, fmt.Errorf("%s is held by another togo process", path)
+			}
+			return nil, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
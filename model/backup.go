@@ -0,0 +1,308 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBackupCount is how many snapshots a BackupStore keeps unless
+// configured otherwise.
+const DefaultBackupCount = 10
+
+// backupTimeLayout names snapshot files after the UTC time they were taken,
+// so that they sort by age.
+const backupTimeLayout = "20060102-150405.000000"
+
+var _ Store = (*BackupStore)(nil)
+
+// BackupStore wraps a store and, on each save, keeps a snapshot of what the
+// store held before in Dir. Only the newest Keep snapshots are kept, and none are
+// taken when Keep is 0.
+type BackupStore struct {
+	Store
+	Dir  string
+	Keep int
+}
+
+// NewBackupStore returns store with snapshots kept in dir.
+func NewBackupStore(store Store, dir string, keep int) *BackupStore {
+	return &BackupStore{Store: store, Dir: dir, Keep: keep}
+}
+
+// Backup is a snapshot of a todo list taken by a BackupStore.
+type Backup struct {
+	// Name identifies the snapshot, e.g. "20260115-093000.000000".
+	Name string
+	Path string
+	Time time.Time
+}
+
+// Save saves tl and then snapshots what the store held before, as tl was
+// loaded, if the save changed it. The snapshot is taken from the list in
+// memory rather than by reading the store again.
+func (s *BackupStore) Save(tl *TodoList) error {
+	before := tl.loaded
+	if err := s.Store.Save(tl); err != nil {
+		return err
+	}
+	if s.Keep == 0 || before == nil || !changedSince(before, tl.loaded) {
+		return nil
+	}
+	data := before.data
+	if data == nil {
+		if len(before.todos) == 0 {
+			return nil
+		}
+		var err error
+		if data, err = json.Marshal(loadedList(before, tl.NextID)); err != nil {
+			return err
+		}
+	}
+	if err := s.write(data); err != nil {
+		return fmt.Errorf("saved, but could not back up the todos: %w", err)
+	}
+	return nil
+}
+
+// changedSince reports whether a save went from the todos of before to
+// those of after. Without the raw contents to compare, todos are compared by
+// ModifiedAt, which every change bumps.
+func changedSince(before, after *snapshot) bool {
+	if before.data != nil && after.data != nil {
+		return !bytes.Equal(before.data, after.data)
+	}
+	if len(before.todos) != len(after.todos) {
+		return true
+	}
+	for uuid, old := range before.todos {
+		todo, ok := after.todos[uuid]
+		if !ok || todo.ID != old.ID || !todo.ModifiedAt.Equal(old.ModifiedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadedList rebuilds the list a snapshot was taken of, for stores that
+// don't keep its raw contents. Handing out IDs carries on from nextID.
+func loadedList(loaded *snapshot, nextID int) *TodoList {
+	tl := &TodoList{SchemaVersion: SchemaVersion, NextID: nextID}
+	for _, todo := range loaded.todos {
+		tl.Todos = append(tl.Todos, todo)
+	}
+	sort.Slice(tl.Todos, func(i, j int) bool {
+		return tl.Todos[i].ID < tl.Todos[j].ID
+	})
+	return tl
+}
+
+// Snapshot reads the stored list and writes a snapshot of it, unless nothing
+// is stored yet or it is the same as the newest snapshot.
+func (s *BackupStore) Snapshot() error {
+	current, err := s.Store.Load()
+	if err != nil {
+		return err
+	}
+	if len(current.Todos) == 0 && current.NextID <= 1 {
+		return nil
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	backups, err := ListBackups(s.Dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 {
+		if latest, err := readDataFile(backups[0].Path); err == nil && bytes.Equal(latest, data) {
+			return nil
+		}
+	}
+	return s.write(data)
+}
+
+// write adds data as the newest snapshot and removes the oldest snapshots
+// beyond Keep.
+func (s *BackupStore) write(data []byte) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format(backupTimeLayout)
+	if err := writeDataFile(filepath.Join(s.Dir, name+".json"), data); err != nil {
+		return err
+	}
+	backups, err := ListBackups(s.Dir)
+	if err != nil {
+		return err
+	}
+	for i := max(s.Keep, 1); i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompactHistory drops the history recorded before the given time from the
+// todos in every snapshot. The todos are otherwise left as they were.
+func (s *BackupStore) CompactHistory(before time.Time) error {
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		tl, err := LoadBackup(backup)
+		if err != nil {
+			return err
+		}
+		dropped := 0
+		for i := range tl.Todos {
+			kept := compactHistory(tl.Todos[i].History, before)
+			dropped += len(tl.Todos[i].History) - len(kept)
+			tl.Todos[i].History = kept
+		}
+		if dropped == 0 {
+			continue
+		}
+		data, err := json.Marshal(tl)
+		if err != nil {
+			return err
+		}
+		if err := writeDataFile(backup.Path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backups returns the snapshots kept by s, newest first.
+func (s *BackupStore) Backups() ([]Backup, error) {
+	return ListBackups(s.Dir)
+}
+
+// ListBackups returns the snapshots in dir, newest first.
+func ListBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		taken, err := time.Parse(backupTimeLayout, name)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Name: name, Path: filepath.Join(dir, entry.Name()), Time: taken.Local()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// FindBackup picks a snapshot from backups, which are newest first, by its
+// number in that order (1 is the newest), its name or a unique prefix of it.
+func FindBackup(backups []Backup, query string) (Backup, error) {
+	if n, err := strconv.Atoi(query); err == nil && len(query) < 4 {
+		if n < 1 || n > len(backups) {
+			return Backup{}, fmt.Errorf("there is no snapshot %d, there are %d", n, len(backups))
+		}
+		return backups[n-1], nil
+	}
+	var matches []Backup
+	for _, backup := range backups {
+		if backup.Name == query {
+			return backup, nil
+		}
+		if strings.HasPrefix(backup.Name, query) {
+			matches = append(matches, backup)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Backup{}, fmt.Errorf("no snapshot called %q", query)
+	case 1:
+		return matches[0], nil
+	}
+	return Backup{}, fmt.Errorf("%q matches %d snapshots", query, len(matches))
+}
+
+// LoadBackup reads a snapshot, upgrading it to the current schema.
+func LoadBackup(backup Backup) (*TodoList, error) {
+	data, err := readDataFile(backup.Path)
+	if err != nil {
+		return nil, err
+	}
+	tl, err := parseTodoList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", backup.Path, err)
+	}
+	return tl, nil
+}
+
+// ListDiff describes how one todo list differs from another.
+type ListDiff struct {
+	// Added are the todos only in the second list.
+	Added []Todo
+	// Removed are the todos only in the first list.
+	Removed []Todo
+	// Changed are the todos of the second list that differ in the first.
+	Changed []Todo
+}
+
+// Empty reports whether the lists hold the same todos.
+func (d ListDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffLists compares the todos of two lists by UUID.
+func DiffLists(from, to *TodoList) ListDiff {
+	var diff ListDiff
+	before := make(map[string]Todo, len(from.Todos))
+	for _, todo := range from.Todos {
+		before[todo.UUID] = todo
+	}
+	for _, todo := range to.Todos {
+		old, ok := before[todo.UUID]
+		delete(before, todo.UUID)
+		if !ok {
+			diff.Added = append(diff.Added, todo)
+			continue
+		}
+		oldData, _ := json.Marshal(old)
+		newData, _ := json.Marshal(todo)
+		if !bytes.Equal(oldData, newData) {
+			diff.Changed = append(diff.Changed, todo)
+		}
+	}
+	for _, todo := range from.Todos {
+		if _, ok := before[todo.UUID]; ok {
+			diff.Removed = append(diff.Removed, todo)
+		}
+	}
+	return diff
+}
+
+// Restore replaces the todos of tl with those of snapshot. Short IDs handed
+// out since the snapshot are not reused.
+func (tl *TodoList) Restore(snapshot *TodoList) {
+	tl.Todos = make([]Todo, len(snapshot.Todos))
+	copy(tl.Todos, snapshot.Todos)
+	tl.NextID = max(tl.NextID, snapshot.NextID)
+	tl.rebuildIndex()
+}
@@ -0,0 +1,387 @@
+package model
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic starts every encrypted file, followed by the scrypt salt,
+// the AES-GCM nonce and the sealed data.
+var encryptedMagic = []byte("togo-encrypted-v1\n")
+
+const (
+	saltSize = 16
+	// The scrypt parameters recommended for interactive logins in 2017,
+	// about 100ms on a laptop.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrEncrypted is returned when reading an encrypted file without a key.
+	ErrEncrypted = errors.New("the todos are encrypted, set TOGO_PASSPHRASE or TOGO_KEYFILE to unlock them")
+	// ErrWrongPassphrase is returned when a key can't decrypt a file.
+	ErrWrongPassphrase = errors.New("wrong passphrase or keyfile")
+)
+
+// Key encrypts and decrypts todo files with AES-256-GCM, using keys derived
+// from a passphrase with scrypt. Every file gets its own random nonce; the
+// salt is chosen once per Key, so deriving it only happens once per run.
+type Key struct {
+	passphrase []byte
+	salt       []byte
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// NewKey returns the key for a passphrase, or the contents of a keyfile.
+func NewKey(passphrase string) (*Key, error) {
+	if passphrase == "" {
+		return nil, errors.New("the passphrase is empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &Key{passphrase: []byte(passphrase), salt: salt, aeads: map[string]cipher.AEAD{}}, nil
+}
+
+// aead derives the cipher for a salt.
+func (k *Key) aead(salt []byte) (cipher.AEAD, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if aead, ok := k.aeads[string(salt)]; ok {
+		return aead, nil
+	}
+	derived, err := scrypt.Key(k.passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	k.aeads[string(salt)] = aead
+	return aead, nil
+}
+
+// Seal encrypts data.
+func (k *Key) Seal(data []byte) ([]byte, error) {
+	aead, err := k.aead(k.salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append(append([]byte{}, encryptedMagic...), k.salt...), nonce...)
+	return aead.Seal(sealed, nonce, data, nil), nil
+}
+
+// Open decrypts data sealed with the same passphrase.
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("not encrypted by togo")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < saltSize {
+		return nil, errors.New("encrypted data cut short")
+	}
+	aead, err := k.aead(data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted data cut short")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// IsEncrypted reports whether data was encrypted by a Key.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// key encrypts the files togo writes when set, and decrypts the encrypted
+// files it reads.
+var key *Key
+
+// SetKey makes togo encrypt the files it writes with k and decrypt the
+// files it reads with it. A nil k turns encryption off again; encrypted
+// files can't be read then.
+func SetKey(k *Key) {
+	key = k
+}
+
+// sealData encrypts data with the key set with SetKey, if any.
+func sealData(data []byte) ([]byte, error) {
+	if key == nil {
+		return data, nil
+	}
+	return key.Seal(data)
+}
+
+// openData decrypts data if it is encrypted.
+func openData(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, ErrEncrypted
+	}
+	return key.Open(data)
+}
+
+// readDataFile returns the decrypted contents of path, or nil if it doesn't
+// exist.
+func readDataFile(path string) ([]byte, error) {
+	data, err := readFileIfExists(path)
+	if err != nil || data == nil {
+		return data, err
+	}
+	data, err = openData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// writeDataFile atomically replaces path with data, encrypted if a key is
+// set, readable by the user only.
+func writeDataFile(path string, data []byte) error {
+	sealed, err := sealData(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed, 0600)
+}
+
+// keyCheckFile marks an encrypted data directory. It holds keyCheckText
+// encrypted with the key, to tell a wrong passphrase from a right one
+// before anything is written with it.
+const keyCheckFile = ".encrypted"
+
+var keyCheckText = []byte("togo")
+
+// IsEncryptedDataDir reports whether the todos in the data directory are
+// encrypted.
+func IsEncryptedDataDir() (bool, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filepath.Join(dataDir, keyCheckFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Unlock checks passphrase against the encrypted data directory and sets
+// its key with SetKey.
+func Unlock(passphrase string) error {
+	k, err := NewKey(passphrase)
+	if err != nil {
+		return err
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	check, err := os.ReadFile(filepath.Join(dataDir, keyCheckFile))
+	if err != nil {
+		return err
+	}
+	if text, err := k.Open(check); err != nil || !bytes.Equal(text, keyCheckText) {
+		return ErrWrongPassphrase
+	}
+	SetKey(k)
+	return nil
+}
+
+// EncryptDataDir encrypts every todo file in the data directory, including
+// journals and backups, with the key for passphrase, and sets the key with
+// SetKey. SQLite databases can't be encrypted, so one left behind by a
+// migration to json has to be removed first.
+func EncryptDataDir(passphrase string) error {
+	k, err := NewKey(passphrase)
+	if err != nil {
+		return err
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	paths, err := dataFiles(dataDir)
+	if err != nil {
+		return err
+	}
+	var sqliteFiles []string
+	for _, path := range paths {
+		// List names have no dots, so only SQLite files have ".db" in them.
+		if strings.Contains(filepath.Base(path), ".db") {
+			sqliteFiles = append(sqliteFiles, path)
+		}
+	}
+	if len(sqliteFiles) > 0 {
+		return fmt.Errorf("SQLite data left by an earlier migration can't be encrypted, remove it first: %s", strings.Join(sqliteFiles, ", "))
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+	// The check goes first, so that files encrypted before a failure can
+	// still be unlocked.
+	check, err := k.Seal(keyCheckText)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dataDir, keyCheckFile), check, 0600); err != nil {
+		return err
+	}
+	SetKey(k)
+	return rewriteFiles(paths)
+}
+
+// DecryptDataDir writes every todo file in the data directory back in plain
+// text. The data directory must have been unlocked first.
+func DecryptDataDir() error {
+	if key == nil {
+		return ErrEncrypted
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	paths, err := dataFiles(dataDir)
+	if err != nil {
+		return err
+	}
+	// Read everything with the key before writing anything without it.
+	contents := make([][]byte, len(paths))
+	for i, path := range paths {
+		if strings.HasSuffix(path, ".journal") {
+			continue
+		}
+		if contents[i], err = readDataFile(path); err != nil {
+			return err
+		}
+	}
+	journals := map[string][]Operation{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".journal") {
+			if journals[path], err = ReadJournal(path); err != nil {
+				return err
+			}
+		}
+	}
+	SetKey(nil)
+	for i, path := range paths {
+		if ops, ok := journals[path]; ok {
+			err = writeJournal(path, ops)
+		} else {
+			err = writeDataFile(path, contents[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.Remove(filepath.Join(dataDir, keyCheckFile))
+}
+
+// dataFiles returns the todo files in dir and its backups: lists, journals,
+// snapshots and the copies kept before upgrades or on conflicts. Locks,
+// temporary files and the key check are left out, and so is ProjectsDir,
+// since project lists are never encrypted.
+func dataFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err == nil && entry.IsDir() && path == filepath.Join(dir, ProjectsDir) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".lock") {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
+
+// rewriteFiles writes each of the files back encrypted with the current
+// key, or in plain text if there is none.
+func rewriteFiles(paths []string) error {
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".journal") {
+			ops, err := ReadJournal(path)
+			if err != nil {
+				return err
+			}
+			if err := writeJournal(path, ops); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := readDataFile(path)
+		if err != nil {
+			return err
+		}
+		if err := writeDataFile(path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealLine encrypts one line of a journal, keeping it on one line.
+func sealLine(line []byte) ([]byte, error) {
+	if key == nil {
+		return line, nil
+	}
+	sealed, err := key.Seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// openLine decrypts a journal line written by sealLine.
+func openLine(line []byte) ([]byte, error) {
+	if len(line) == 0 || line[0] == '{' {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return line, nil
+	}
+	return openData(sealed)
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// dataDirOverride is set by SetDataDir, e.g. from the --data-dir flag.
+var dataDirOverride string
+
+// SetDataDir makes DataDir return dir. An empty dir restores the default.
+func SetDataDir(dir string) {
+	dataDirOverride = dir
+}
+
+// DataDir returns the directory todos are stored in: the directory given to
+// SetDataDir, else UserDataDir.
+func DataDir() (string, error) {
+	if dataDirOverride != "" {
+		return dataDirOverride, nil
+	}
+	return UserDataDir()
+}
+
+// UserDataDir returns the user's own data directory, $TOGO_DATA_DIR or else
+// DefaultDataDir, whatever was given to SetDataDir.
+func UserDataDir() (string, error) {
+	if dir := os.Getenv("TOGO_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	return DefaultDataDir()
+}
+
+// DefaultDataDir returns $XDG_DATA_HOME/togo, which defaults to
+// ~/.local/share/togo. macOS and Windows use their application data
+// directory instead, e.g. ~/Library/Application Support/togo.
+func DefaultDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "togo"), nil
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("could not determine user data directory: %w", err)
+		}
+		return filepath.Join(configDir, "togo"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine user home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "togo"), nil
+}
+
+// legacyDataDir is where togo kept its data before it moved to DataDir.
+func legacyDataDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "togo"), nil
+}
+
+// MigrateLegacyDataDir moves the files of older versions of togo, which kept
+// them in the user cache directory, into the default data directory. It does
+// nothing once the data directory holds files of its own, and returns the
+// directory the files were moved from, or "" if nothing was moved.
+func MigrateLegacyDataDir() (string, error) {
+	legacyDir, err := legacyDataDir()
+	if err != nil {
+		return "", nil
+	}
+	dataDir, err := DefaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Clean(legacyDir) == filepath.Clean(dataDir) {
+		return "", nil
+	}
+	legacy, err := os.ReadDir(legacyDir)
+	if err != nil || len(legacy) == 0 {
+		return "", nil
+	}
+	if existing, err := os.ReadDir(dataDir); err == nil && len(existing) > 0 {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return "", err
+	}
+	for _, entry := range legacy {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		from, to := filepath.Join(legacyDir, entry.Name()), filepath.Join(dataDir, entry.Name())
+		if err := moveFile(from, to); err != nil {
+			return "", fmt.Errorf("could not move %s to %s: %w", from, dataDir, err)
+		}
+	}
+	os.Remove(legacyDir)
+	return legacyDir, nil
+}
+
+// moveFile renames from to to, copying it when they are on different
+// file systems.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
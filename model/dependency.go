@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddDependency records that id cannot be completed before onID. Dependencies
+// that would make a task wait on itself, directly or through other tasks,
+// are rejected.
+func (tl *TodoList) AddDependency(id, onID int) error {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return fmt.Errorf("todo %d not found", id)
+	}
+	blocker := tl.GetTodoByID(onID)
+	if blocker == nil {
+		return fmt.Errorf("todo %d not found", onID)
+	}
+	if id == onID {
+		return fmt.Errorf("\"%s\" cannot depend on itself", blocker.Title)
+	}
+	for _, dep := range tl.Todos[idx].DependsOn {
+		if dep == onID {
+			return fmt.Errorf("\"%s\" already depends on \"%s\"", tl.Todos[idx].Title, blocker.Title)
+		}
+	}
+	if path := tl.dependencyPath(onID, id, map[int]bool{}); path != nil {
+		titles := []string{tl.Todos[idx].Title}
+		for _, step := range path {
+			titles = append(titles, tl.GetTodoByID(step).Title)
+		}
+		return fmt.Errorf("dependency would create a cycle: %s", strings.Join(titles, " → "))
+	}
+	tl.Todos[idx].DependsOn = append(tl.Todos[idx].DependsOn, onID)
+	tl.touch(idx)
+	return nil
+}
+
+// RemoveDependency drops the dependency of id on onID.
+func (tl *TodoList) RemoveDependency(id, onID int) error {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return fmt.Errorf("todo %d not found", id)
+	}
+	deps := tl.Todos[idx].DependsOn
+	for i, dep := range deps {
+		if dep == onID {
+			tl.Todos[idx].DependsOn = append(deps[:i:i], deps[i+1:]...)
+			tl.touch(idx)
+			return nil
+		}
+	}
+	return fmt.Errorf("\"%s\" does not depend on todo %d", tl.Todos[idx].Title, onID)
+}
+
+// GetDependencies returns the todos id depends on, finished or not.
+func (tl *TodoList) GetDependencies(id int) []Todo {
+	todo := tl.GetTodoByID(id)
+	if todo == nil {
+		return nil
+	}
+	var deps []Todo
+	for _, depID := range todo.DependsOn {
+		if dep := tl.GetTodoByID(depID); dep != nil {
+			deps = append(deps, *dep)
+		}
+	}
+	return deps
+}
+
+// Blockers returns the unfinished todos that id is still waiting on.
+func (tl *TodoList) Blockers(id int) []Todo {
+	var blockers []Todo
+	for _, dep := range tl.GetDependencies(id) {
+		if !dep.Completed {
+			blockers = append(blockers, dep)
+		}
+	}
+	return blockers
+}
+
+// IsBlocked reports whether id still waits on an unfinished todo.
+func (tl *TodoList) IsBlocked(id int) bool {
+	return len(tl.Blockers(id)) > 0
+}
+
+// GetDependents returns the todos that depend on id.
+func (tl *TodoList) GetDependents(id int) []Todo {
+	var dependents []Todo
+	for _, todo := range tl.Todos {
+		for _, dep := range todo.DependsOn {
+			if dep == id {
+				dependents = append(dependents, todo)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// dependencyPath returns the chain of dependencies leading from one todo to
+// another, or nil if there is none.
+func (tl *TodoList) dependencyPath(from, to int, visited map[int]bool) []int {
+	if from == to {
+		return []int{to}
+	}
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+	todo := tl.GetTodoByID(from)
+	if todo == nil {
+		return nil
+	}
+	for _, dep := range todo.DependsOn {
+		if path := tl.dependencyPath(dep, to, visited); path != nil {
+			return append([]int{from}, path...)
+		}
+	}
+	return nil
+}
+
+// removeDependenciesOn forgets every dependency on id, e.g. once it is deleted.
+func (tl *TodoList) removeDependenciesOn(id int) {
+	for i := range tl.Todos {
+		var deps []int
+		for _, dep := range tl.Todos[i].DependsOn {
+			if dep != id {
+				deps = append(deps, dep)
+			}
+		}
+		if len(deps) != len(tl.Todos[i].DependsOn) {
+			tl.touch(i)
+		}
+		tl.Todos[i].DependsOn = deps
+	}
+}
+
+// FormatTitles joins todo titles for messages, e.g. `"a", "b"`.
+func FormatTitles(todos []Todo) string {
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = fmt.Sprintf("\"%s\"", todo.Title)
+	}
+	return strings.Join(titles, ", ")
+}
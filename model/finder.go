@@ -0,0 +1,121 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Finder is a store that can look todos up without loading the whole list,
+// for shell completion. SQLiteStore answers these from its indexes; for other
+// stores the functions ActiveTodos and ArchivedTodos load the list and search
+// it instead. Commands that change todos load the whole list either way.
+type Finder interface {
+	// ActiveTodos returns the todos that are not archived, by ID.
+	ActiveTodos() ([]Todo, error)
+	// ArchivedTodos returns the archived todos, by ID.
+	ArchivedTodos() ([]Todo, error)
+}
+
+var _ Finder = (*SQLiteStore)(nil)
+
+// finderOf returns the finder behind store, looking through the stores that
+// wrap another, or nil if there is none. Backups and the journal never change
+// what a store loads, so the inner store can be asked directly.
+func finderOf(store Store) Finder {
+	for {
+		switch s := store.(type) {
+		case Finder:
+			return s
+		case *BackupStore:
+			store = s.Store
+		case *JournalStore:
+			store = s.Store
+		default:
+			return nil
+		}
+	}
+}
+
+// ActiveTodos returns the todos of store that are not archived.
+func ActiveTodos(store Store) ([]Todo, error) {
+	if finder := finderOf(store); finder != nil {
+		return finder.ActiveTodos()
+	}
+	tl, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return tl.GetActiveTodos(), nil
+}
+
+// ArchivedTodos returns the archived todos of store.
+func ArchivedTodos(store Store) ([]Todo, error) {
+	if finder := finderOf(store); finder != nil {
+		return finder.ArchivedTodos()
+	}
+	tl, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return tl.GetArchivedTodos(), nil
+}
+
+// ActiveTodos reads the todos that are not archived.
+func (s *SQLiteStore) ActiveTodos() ([]Todo, error) {
+	return s.queryTodos(`archived = 0 ORDER BY id`)
+}
+
+// ArchivedTodos reads the archived todos.
+func (s *SQLiteStore) ArchivedTodos() ([]Todo, error) {
+	return s.queryTodos(`archived = 1 ORDER BY id`)
+}
+
+// queryTodos reads the todos matching where, a condition on the indexed
+// columns followed by an ORDER BY clause. A database written with an older
+// schema is upgraded by loading it first, since its rows may not be in the
+// current form yet.
+func (s *SQLiteStore) queryTodos(where string, args ...any) ([]Todo, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	var value string
+	err = db.QueryRow(`SELECT value FROM meta WHERE key = 'schema_version'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		db.Close()
+		return nil, nil
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	if version, _ := strconv.Atoi(value); version != SchemaVersion {
+		db.Close()
+		if _, err := s.Load(); err != nil {
+			return nil, err
+		}
+		return s.queryTodos(where, args...)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT data FROM todos WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	defer rows.Close()
+	var todos []Todo
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var todo Todo
+		if err := json.Unmarshal([]byte(data), &todo); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Path, err)
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldCreated is the Field of the history entry recording that a todo was
+// created.
+const FieldCreated = "created"
+
+// FieldChange is one entry of a todo's history: a field that changed from
+// Old to New, when and by whom. Values are kept as text, the way they are
+// shown, and are empty when the field was unset.
+type FieldChange struct {
+	Field string    `json:"field"`
+	Old   string    `json:"old,omitempty"`
+	New   string    `json:"new,omitempty"`
+	At    time.Time `json:"at"`
+	By    string    `json:"by,omitempty"`
+}
+
+// String describes the change, e.g. `deadline: 2026-10-20 09:00 → 2026-10-22
+// 09:00`. Long values such as notes are cut short.
+func (c FieldChange) String() string {
+	if c.Field == FieldCreated {
+		return fmt.Sprintf("created %q", c.New)
+	}
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("%s: set to %s", c.Field, shortValue(c.New))
+	case c.New == "":
+		return fmt.Sprintf("%s: cleared (was %s)", c.Field, shortValue(c.Old))
+	}
+	return fmt.Sprintf("%s: %s → %s", c.Field, shortValue(c.Old), shortValue(c.New))
+}
+
+// shortValue puts a value on one line of at most 40 characters.
+func shortValue(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if runes := []rune(value); len(runes) > 40 {
+		value = string(runes[:39]) + "…"
+	}
+	return value
+}
+
+// historyFields are the fields whose changes are recorded, in the order
+// they are listed, with how each is shown.
+var historyFields = []struct {
+	name  string
+	value func(Todo) string
+}{
+	{"title", func(t Todo) string { return t.Title }},
+	{"completed", func(t Todo) string { return strconv.FormatBool(t.Completed) }},
+	{"archived", func(t Todo) string { return strconv.FormatBool(t.Archived) }},
+	{"deadline", func(t Todo) string {
+		if t.Deadline == nil {
+			return ""
+		}
+		return t.Deadline.Format("2006-01-02 15:04")
+	}},
+	{"hard_deadline", func(t Todo) string { return strconv.FormatBool(t.HardDeadline) }},
+	{"priority", func(t Todo) string {
+		if t.Priority == PriorityNone {
+			return ""
+		}
+		return t.Priority.String()
+	}},
+	{"project", func(t Todo) string { return t.Project }},
+	{"tags", func(t Todo) string { return FormatTags(t.Tags) }},
+	{"notes", func(t Todo) string { return t.Notes }},
+	{"parent", func(t Todo) string { return formatID(t.ParentID) }},
+	{"depends_on", func(t Todo) string {
+		ids := make([]string, len(t.DependsOn))
+		for i, id := range t.DependsOn {
+			ids[i] = strconv.Itoa(id)
+		}
+		return strings.Join(ids, ", ")
+	}},
+	{"recurrence", func(t Todo) string { return t.Recurrence }},
+}
+
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// fieldChanges lists the fields that differ between two versions of a todo.
+func fieldChanges(before, after Todo, at time.Time) []FieldChange {
+	var changes []FieldChange
+	for _, field := range historyFields {
+		was, now := field.value(before), field.value(after)
+		if was != now {
+			changes = append(changes, FieldChange{Field: field.name, Old: was, New: now, At: at, By: currentUser()})
+		}
+	}
+	return changes
+}
+
+// recordHistory appends the changes from before to todo to its history. A
+// nil before records that the todo was created.
+func recordHistory(todo, before *Todo, at time.Time) {
+	if before == nil {
+		todo.History = append(todo.History, FieldChange{Field: FieldCreated, New: todo.Title, At: at, By: currentUser()})
+		return
+	}
+	todo.History = append(todo.History, fieldChanges(*before, *todo, at)...)
+}
+
+// currentUser is who history entries are recorded for: $TOGO_USER, or else
+// the name of the logged in user.
+func currentUser() string {
+	if name := os.Getenv("TOGO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// Purgeable returns the archived todos that Purge would delete: those
+// archived before the given time. Todos archived before archive times were
+// recorded count from when they were last modified.
+func (tl *TodoList) Purgeable(before time.Time) []Todo {
+	var todos []Todo
+	for _, todo := range tl.Todos {
+		if !todo.Archived {
+			continue
+		}
+		archivedAt, ok := todo.Time(TimeArchived)
+		if !ok {
+			archivedAt = todo.ModifiedAt
+		}
+		if archivedAt.Before(before) {
+			todos = append(todos, todo)
+		}
+	}
+	return todos
+}
+
+// Purge permanently deletes the todos archived before the given time, along
+// with their history, and returns them. Subtasks that are not archived
+// themselves move up a level.
+func (tl *TodoList) Purge(before time.Time) []Todo {
+	var purged []Todo
+	for _, todo := range tl.Purgeable(before) {
+		if tl.Delete(todo.ID) {
+			purged = append(purged, todo)
+		}
+	}
+	return purged
+}
+
+// CompactHistory drops the history entries recorded before the given time
+// from every todo, and returns how many were dropped. Todos that lost
+// entries count as modified, so that saving or merging them doesn't bring
+// the entries back.
+func (tl *TodoList) CompactHistory(before time.Time) int {
+	count := 0
+	for i := range tl.Todos {
+		kept := compactHistory(tl.Todos[i].History, before)
+		if dropped := len(tl.Todos[i].History) - len(kept); dropped > 0 {
+			count += dropped
+			tl.Todos[i].History = kept
+			tl.touch(i)
+		}
+	}
+	return count
+}
+
+// compactHistory returns history without the entries recorded before the
+// given time, or nil if none are left.
+func compactHistory(history []FieldChange, before time.Time) []FieldChange {
+	kept := slices.DeleteFunc(slices.Clone(history), func(change FieldChange) bool {
+		return change.At.Before(before)
+	})
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
@@ -0,0 +1,624 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// undoLimit is how many operations can be undone in a row.
+const undoLimit = 100
+
+// journalCheckpoint is how many entries a journal may grow to before
+// JournalStore rewrites it with just the operations that can still be undone
+// and redone.
+const journalCheckpoint = 2 * undoLimit
+
+var (
+	// ErrNothingToUndo is returned by Undo when there is no operation left to
+	// undo.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is returned by Redo when nothing has been undone since
+	// the last operation.
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Change is what one operation did to one todo. Before is nil for a todo the
+// operation added and After is nil for one it deleted. The todos are kept
+// without their history, which the todo in the list carries on with, except
+// for a deleted todo so that undoing the delete brings its history back.
+type Change struct {
+	UUID   string `json:"uuid"`
+	Before *Todo  `json:"before"`
+	After  *Todo  `json:"after"`
+}
+
+// Operation is one entry of the journal: a set of changes made together,
+// such as a command, a TUI action or a bulk action on several todos.
+type Operation struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Undoes and Redoes are set on the operations made by Undo and Redo to
+	// the ID of the operation they undid or redid.
+	Undoes  string   `json:"undoes,omitempty"`
+	Redoes  string   `json:"redoes,omitempty"`
+	Changes []Change `json:"changes"`
+}
+
+// history tracks the operations made on a list. base is how the todos were
+// at the last commit, done and undone are the undo and redo stacks, and
+// pending holds the operations not yet written to the journal.
+type history struct {
+	base    map[string]Todo
+	done    []Operation
+	undone  []Operation
+	pending []Operation
+}
+
+// markClean makes the current todos the state the next operation is
+// measured against.
+func (tl *TodoList) markClean() {
+	tl.history.base = make(map[string]Todo, len(tl.Todos))
+	for _, todo := range tl.Todos {
+		tl.history.base[todo.UUID] = cloneTodo(todo)
+	}
+}
+
+// Commit records everything changed since the last commit as one operation,
+// so that it can be undone in one go. action describes it, e.g. "delete 3
+// todos"; when empty a description is made up from the changes. Stores that
+// keep a journal commit on save, so Commit only needs to be called to split
+// the changes made before a save into several operations.
+func (tl *TodoList) Commit(action string) {
+	if tl.history.base == nil {
+		tl.history.base = map[string]Todo{}
+	}
+	var changes []Change
+	current := make(map[string]bool, len(tl.Todos))
+	for _, todo := range tl.Todos {
+		current[todo.UUID] = true
+		old, ok := tl.history.base[todo.UUID]
+		if ok && sameFields(old, todo) {
+			continue
+		}
+		change := Change{UUID: todo.UUID, After: todoPtr(cloneTodo(todo))}
+		if ok {
+			change.Before = todoPtr(old)
+		}
+		changes = append(changes, withoutHistory(change))
+	}
+	for uuid, old := range tl.history.base {
+		if !current[uuid] {
+			changes = append(changes, withoutHistory(Change{UUID: uuid, Before: todoPtr(old)}))
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changeID(changes[i]) < changeID(changes[j])
+	})
+	if action == "" {
+		action = describeChanges(changes)
+	}
+	op := Operation{ID: newUUID(), Time: time.Now(), Action: action, Changes: changes}
+	for _, change := range changes {
+		if change.After != nil {
+			recordHistory(tl.GetTodoByUUID(change.UUID), change.Before, op.Time)
+		}
+	}
+	tl.pushDone(op)
+	tl.history.undone = nil
+	tl.history.pending = append(tl.history.pending, op)
+	tl.markClean()
+}
+
+// Undo reverts the most recent operation that is not undone yet and returns
+// it. Any changes not committed yet are committed first. It refuses when a
+// todo the operation touched has been changed since, by another operation or
+// another togo process.
+func (tl *TodoList) Undo() (Operation, error) {
+	tl.Commit("")
+	if len(tl.history.done) == 0 {
+		return Operation{}, ErrNothingToUndo
+	}
+	op := tl.history.done[len(tl.history.done)-1]
+	changes, err := tl.applyChanges(op, true)
+	if err != nil {
+		return op, err
+	}
+	tl.history.done = tl.history.done[:len(tl.history.done)-1]
+	tl.history.undone = append(tl.history.undone, op)
+	tl.history.pending = append(tl.history.pending, Operation{
+		ID: newUUID(), Time: time.Now(), Action: "undo " + op.Action, Undoes: op.ID, Changes: changes,
+	})
+	tl.markClean()
+	return op, nil
+}
+
+// Redo makes the most recently undone operation again and returns it.
+func (tl *TodoList) Redo() (Operation, error) {
+	tl.Commit("")
+	if len(tl.history.undone) == 0 {
+		return Operation{}, ErrNothingToRedo
+	}
+	op := tl.history.undone[len(tl.history.undone)-1]
+	changes, err := tl.applyChanges(op, false)
+	if err != nil {
+		return op, err
+	}
+	tl.history.undone = tl.history.undone[:len(tl.history.undone)-1]
+	tl.pushDone(op)
+	tl.history.pending = append(tl.history.pending, Operation{
+		ID: newUUID(), Time: time.Now(), Action: "redo " + op.Action, Redoes: op.ID, Changes: changes,
+	})
+	tl.markClean()
+	return op, nil
+}
+
+// CanUndo reports whether there is an operation to undo.
+func (tl *TodoList) CanUndo() bool {
+	return len(tl.history.done) > 0
+}
+
+// CanRedo reports whether there is an undone operation to redo.
+func (tl *TodoList) CanRedo() bool {
+	return len(tl.history.undone) > 0
+}
+
+func (tl *TodoList) pushDone(op Operation) {
+	tl.history.done = append(tl.history.done, op)
+	if len(tl.history.done) > undoLimit {
+		tl.history.done = slices.Delete(tl.history.done, 0, len(tl.history.done)-undoLimit)
+	}
+}
+
+// applyChanges puts the todos op changed back the way they were before it,
+// or the way it left them when undo is false, and returns the changes that
+// made. Every todo must still be the way op left it, or was before it.
+func (tl *TodoList) applyChanges(op Operation, undo bool) ([]Change, error) {
+	verb := "redo"
+	if undo {
+		verb = "undo"
+	}
+	var conflicts []string
+	for _, change := range op.Changes {
+		want := change.Before
+		if undo {
+			want = change.After
+		}
+		current := tl.GetTodoByUUID(change.UUID)
+		switch {
+		case current == nil && want == nil:
+		case current == nil:
+			conflicts = append(conflicts, fmt.Sprintf("%q (deleted)", want.Title))
+		case want == nil || !sameTodo(*current, *want):
+			conflicts = append(conflicts, fmt.Sprintf("%q", current.Title))
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("can't %s %s, changed since: %s", verb, op.Action, strings.Join(conflicts, ", "))
+	}
+
+	now := time.Now()
+	var applied []Change
+	for _, change := range op.Changes {
+		target := change.Before
+		if !undo {
+			target = change.After
+		}
+		made := Change{UUID: change.UUID}
+		if current := tl.GetTodoByUUID(change.UUID); current != nil {
+			made.Before = todoPtr(cloneTodo(*current))
+		}
+		if target == nil {
+			tl.removeByUUID(change.UUID)
+		} else {
+			todo := cloneTodo(*target)
+			todo.ModifiedAt = now
+			if made.Before != nil {
+				// The history goes on, recording the undo or redo as well.
+				todo.History = made.Before.History
+				todo.History = append(todo.History, fieldChanges(*made.Before, todo, now)...)
+			}
+			tl.putTodo(todo)
+			made.After = todoPtr(cloneTodo(todo))
+		}
+		applied = append(applied, withoutHistory(made))
+	}
+	tl.rebuildIndex()
+	return applied, nil
+}
+
+// removeByUUID deletes a todo without touching any other.
+func (tl *TodoList) removeByUUID(uuid string) {
+	tl.Todos = slices.DeleteFunc(tl.Todos, func(todo Todo) bool {
+		return todo.UUID == uuid
+	})
+	tl.rebuildIndex()
+}
+
+// putTodo replaces the todo with the same UUID, or puts todo back in its
+// place by ID.
+func (tl *TodoList) putTodo(todo Todo) {
+	for i := range tl.Todos {
+		if tl.Todos[i].UUID == todo.UUID {
+			tl.Todos[i] = todo
+			return
+		}
+	}
+	if other := tl.GetTodoByID(todo.ID); other != nil {
+		todo.ID = tl.NextID
+	}
+	tl.NextID = max(tl.NextID, todo.ID+1)
+	idx, _ := slices.BinarySearchFunc(tl.Todos, todo.ID, func(t Todo, id int) int {
+		return t.ID - id
+	})
+	tl.Todos = slices.Insert(tl.Todos, idx, todo)
+	tl.rebuildIndex()
+}
+
+// sameTodo reports whether two todos are the same apart from when they were
+// last modified and their history, which undoing and redoing update.
+func sameTodo(a, b Todo) bool {
+	a.ModifiedAt, b.ModifiedAt = time.Time{}, time.Time{}
+	a.History, b.History = nil, nil
+	aData, _ := json.Marshal(a)
+	bData, _ := json.Marshal(b)
+	return string(aData) == string(bData)
+}
+
+// sameFields reports whether two todos are the same apart from their
+// history.
+func sameFields(a, b Todo) bool {
+	a.History, b.History = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// cloneTodo returns a copy of todo that shares no slices or pointers with it.
+func cloneTodo(todo Todo) Todo {
+	todo.Tags = slices.Clone(todo.Tags)
+	todo.DependsOn = slices.Clone(todo.DependsOn)
+	todo.History = slices.Clone(todo.History)
+	for _, t := range []**time.Time{&todo.Deadline, &todo.CompletedAt, &todo.ArchivedAt} {
+		if *t != nil {
+			copied := **t
+			*t = &copied
+		}
+	}
+	return todo
+}
+
+// withoutHistory returns change with the history left out of its todos,
+// unless it deleted the todo.
+func withoutHistory(change Change) Change {
+	if change.After == nil {
+		return change
+	}
+	after := *change.After
+	after.History = nil
+	change.After = &after
+	if change.Before != nil {
+		before := *change.Before
+		before.History = nil
+		change.Before = &before
+	}
+	return change
+}
+
+func todoPtr(todo Todo) *Todo {
+	return &todo
+}
+
+func changeID(change Change) int {
+	if change.After != nil {
+		return change.After.ID
+	}
+	return change.Before.ID
+}
+
+// describeChanges sums up an operation, e.g. `complete "Write docs"` or
+// "delete 3 todos".
+func describeChanges(changes []Change) string {
+	verbs := make([]string, len(changes))
+	for i, change := range changes {
+		switch {
+		case change.Before == nil:
+			verbs[i] = "add"
+		case change.After == nil:
+			verbs[i] = "delete"
+		case change.Before.Archived != change.After.Archived:
+			verbs[i] = "unarchive"
+			if change.After.Archived {
+				verbs[i] = "archive"
+			}
+		case change.Before.Completed != change.After.Completed:
+			verbs[i] = "reopen"
+			if change.After.Completed {
+				verbs[i] = "complete"
+			}
+		case change.Before.Title != change.After.Title:
+			verbs[i] = "rename"
+		default:
+			verbs[i] = "edit"
+		}
+	}
+	if len(changes) == 1 {
+		title := changes[0].Before
+		if title == nil {
+			title = changes[0].After
+		}
+		return fmt.Sprintf("%s %q", verbs[0], title.Title)
+	}
+	for _, verb := range verbs[1:] {
+		if verb != verbs[0] {
+			return fmt.Sprintf("change %d todos", len(changes))
+		}
+	}
+	return fmt.Sprintf("%s %d todos", verbs[0], len(changes))
+}
+
+var _ Store = (*JournalStore)(nil)
+
+// JournalStore wraps a store and appends the operations made on a list to a
+// journal file, one JSON object per line, each time it is saved. Loading a
+// list reads the journal back, so that operations made by earlier runs can
+// be undone.
+type JournalStore struct {
+	Store
+	Path string
+	// entries counts the entries in the journal, as far as this store knows.
+	entries int
+}
+
+// NewJournalStore returns store with its operations appended to the
+// journal at path.
+func NewJournalStore(store Store, path string) *JournalStore {
+	return &JournalStore{Store: store, Path: path}
+}
+
+// Load loads the list and the operations that can be undone and redone.
+func (s *JournalStore) Load() (*TodoList, error) {
+	tl, err := s.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := ReadJournal(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		tl.replay(op)
+	}
+	s.entries = len(ops)
+	tl.markClean()
+	return tl, nil
+}
+
+// Save commits the changes made since the last commit, saves the list and
+// appends the new operations to the journal. It holds the journal's lock
+// throughout, so the journal lists operations in the order they were saved
+// and a checkpoint never drops what another process appends.
+func (s *JournalStore) Save(tl *TodoList) error {
+	release, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tl.Commit("")
+	if err := s.Store.Save(tl); err != nil {
+		return err
+	}
+	if len(tl.history.pending) > 0 {
+		if err := appendJournal(s.Path, tl.history.pending); err != nil {
+			return fmt.Errorf("saved, but could not write the journal: %w", err)
+		}
+		s.entries += len(tl.history.pending)
+		tl.history.pending = nil
+		if s.entries > journalCheckpoint {
+			if err := s.checkpoint(); err != nil {
+				return fmt.Errorf("saved, but could not compact the journal: %w", err)
+			}
+		}
+	}
+	// Whatever another process changed and the save merged in is its own
+	// operation, not one of ours.
+	tl.markClean()
+	return nil
+}
+
+// checkpoint rewrites the journal with only the entries needed to rebuild
+// its undo and redo stacks. The journal is read again, since other togo
+// processes may have added to it before the lock was taken.
+func (s *JournalStore) checkpoint() error {
+	ops, err := ReadJournal(s.Path)
+	if err != nil {
+		return err
+	}
+	var replayed TodoList
+	for _, op := range ops {
+		replayed.replay(op)
+	}
+	h := replayed.history
+	// The undone operations are written as done, the most recently undone
+	// first, and then undone again in the order they were undone.
+	kept := slices.Clone(h.done)
+	for i := len(h.undone) - 1; i >= 0; i-- {
+		kept = append(kept, h.undone[i])
+	}
+	for _, op := range h.undone {
+		kept = append(kept, Operation{ID: newUUID(), Time: op.Time, Action: "undo " + op.Action, Undoes: op.ID})
+	}
+	if err := writeJournal(s.Path, kept); err != nil {
+		return err
+	}
+	s.entries = len(kept)
+	return nil
+}
+
+// replay updates the undo and redo stacks with an operation read from the
+// journal.
+func (tl *TodoList) replay(op Operation) {
+	h := &tl.history
+	switch {
+	case op.Undoes != "":
+		if i := slices.IndexFunc(h.done, func(done Operation) bool { return done.ID == op.Undoes }); i >= 0 {
+			h.undone = append(h.undone, h.done[i])
+			h.done = slices.Delete(h.done, i, i+1)
+		}
+	case op.Redoes != "":
+		if i := slices.IndexFunc(h.undone, func(undone Operation) bool { return undone.ID == op.Redoes }); i >= 0 {
+			tl.pushDone(h.undone[i])
+			h.undone = slices.Delete(h.undone, i, i+1)
+		}
+	default:
+		tl.pushDone(op)
+		h.undone = nil
+	}
+}
+
+// ReadJournal returns the operations in the journal at path, oldest first.
+// A missing journal is empty.
+func ReadJournal(path string) ([]Operation, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ops []Operation
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line, err := openLine(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		var op Operation
+		if err := json.Unmarshal(line, &op); err != nil {
+			// Skip empty lines and lines cut short by a crash.
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, scanner.Err()
+}
+
+// Forget removes every trace of the todos with the given UUIDs from the
+// journal, dropping the operations that only changed those todos. Their
+// earlier versions can't be brought back by undo afterwards.
+func (s *JournalStore) Forget(uuids []string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	release, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ops, err := ReadJournal(s.Path)
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	kept := ops[:0]
+	for _, op := range ops {
+		op.Changes = slices.DeleteFunc(op.Changes, func(change Change) bool {
+			return slices.Contains(uuids, change.UUID)
+		})
+		if len(op.Changes) > 0 || op.Undoes != "" || op.Redoes != "" {
+			kept = append(kept, op)
+		}
+	}
+	return writeJournal(s.Path, kept)
+}
+
+// CompactHistory drops the history recorded before the given time from the
+// todos kept in the journal, as TodoList.CompactHistory does for the list.
+func (s *JournalStore) CompactHistory(before time.Time) error {
+	release, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	ops, err := ReadJournal(s.Path)
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	for _, op := range ops {
+		for _, change := range op.Changes {
+			for _, todo := range []*Todo{change.Before, change.After} {
+				if todo != nil {
+					todo.History = compactHistory(todo.History, before)
+				}
+			}
+		}
+	}
+	return writeJournal(s.Path, ops)
+}
+
+// lock takes the journal's lock file, Path + ".lock". It is separate from the
+// lock of the store, which Save takes inside it.
+func (s *JournalStore) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return nil, err
+	}
+	return acquireLock(s.Path + ".lock")
+}
+
+// writeJournal replaces the journal at path with ops.
+func writeJournal(path string, ops []Operation) error {
+	data, err := encodeJournal(ops)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// encodeJournal returns ops as journal lines.
+func encodeJournal(ops []Operation) ([]byte, error) {
+	var data []byte
+	for _, op := range ops {
+		line, err := json.Marshal(op)
+		if err != nil {
+			return nil, err
+		}
+		if line, err = sealLine(line); err != nil {
+			return nil, err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
+
+// appendJournal writes ops to the end of the journal at path.
+func appendJournal(path string, ops []Operation) error {
+	data, err := encodeJournal(ops)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
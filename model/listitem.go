@@ -0,0 +1,134 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListItem is a todo as 'togo list' prints it for scripts, with its status
+// worked out. Its JSON form is stable: every field is always present, null
+// or empty when unset, and fields are only ever added, never renamed or
+// removed.
+type ListItem struct {
+	ID           int           `json:"id"`
+	UUID         string        `json:"uuid"`
+	Title        string        `json:"title"`
+	Status       string        `json:"status"`
+	Completed    bool          `json:"completed"`
+	Archived     bool          `json:"archived"`
+	Blocked      bool          `json:"blocked"`
+	Priority     string        `json:"priority"`
+	Deadline     *time.Time    `json:"deadline"`
+	HardDeadline bool          `json:"hard_deadline"`
+	Project      string        `json:"project"`
+	Tags         []string      `json:"tags"`
+	Notes        string        `json:"notes"`
+	ParentID     *int          `json:"parent_id"`
+	DependsOn    []int         `json:"depends_on"`
+	Recurrence   string        `json:"recurrence"`
+	RecursFrom   *int          `json:"recurs_from"`
+	CreatedAt    time.Time     `json:"created_at"`
+	ModifiedAt   *time.Time    `json:"modified_at"`
+	CompletedAt  *time.Time    `json:"completed_at"`
+	ArchivedAt   *time.Time    `json:"archived_at"`
+	History      []FieldChange `json:"history"`
+}
+
+// NewListItem returns the list item for todo, one of the todos of tl.
+func NewListItem(tl *TodoList, todo Todo) ListItem {
+	item := ListItem{
+		ID:           todo.ID,
+		UUID:         todo.UUID,
+		Title:        todo.Title,
+		Status:       "pending",
+		Completed:    todo.Completed,
+		Archived:     todo.Archived,
+		Blocked:      !todo.Completed && tl.IsBlocked(todo.ID),
+		Priority:     strings.ToLower(todo.Priority.String()),
+		Deadline:     todo.Deadline,
+		HardDeadline: todo.HardDeadline,
+		Project:      todo.Project,
+		Tags:         append([]string{}, todo.Tags...),
+		Notes:        todo.Notes,
+		DependsOn:    append([]int{}, todo.DependsOn...),
+		Recurrence:   todo.Recurrence,
+		CreatedAt:    todo.CreatedAt,
+		History:      append([]FieldChange{}, todo.History...),
+	}
+	switch {
+	case item.Completed:
+		item.Status = "completed"
+	case item.Blocked:
+		item.Status = "blocked"
+	}
+	if todo.ParentID != 0 {
+		item.ParentID = &todo.ParentID
+	}
+	if todo.RecursFrom != 0 {
+		item.RecursFrom = &todo.RecursFrom
+	}
+	if t, ok := todo.Time(TimeModified); ok {
+		item.ModifiedAt = &t
+	}
+	if t, ok := todo.Time(TimeCompleted); ok {
+		item.CompletedAt = &t
+	}
+	if t, ok := todo.Time(TimeArchived); ok {
+		item.ArchivedAt = &t
+	}
+	return item
+}
+
+// ListItemFields are the names of the fields of a ListItem in CSV and TSV
+// output, in order. History is left out.
+var ListItemFields = []string{
+	"id", "uuid", "title", "status", "completed", "archived", "blocked",
+	"priority", "deadline", "hard_deadline", "project", "tags", "notes",
+	"parent_id", "depends_on", "recurrence", "recurs_from",
+	"created_at", "modified_at", "completed_at", "archived_at",
+}
+
+// Record returns the fields of the item named by ListItemFields as text.
+// Times are in RFC 3339 format, and lists are separated by commas.
+func (item ListItem) Record() []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	formatInt := func(n *int) string {
+		if n == nil {
+			return ""
+		}
+		return strconv.Itoa(*n)
+	}
+	dependsOn := make([]string, len(item.DependsOn))
+	for i, id := range item.DependsOn {
+		dependsOn[i] = strconv.Itoa(id)
+	}
+	return []string{
+		strconv.Itoa(item.ID),
+		item.UUID,
+		item.Title,
+		item.Status,
+		strconv.FormatBool(item.Completed),
+		strconv.FormatBool(item.Archived),
+		strconv.FormatBool(item.Blocked),
+		item.Priority,
+		formatTime(item.Deadline),
+		strconv.FormatBool(item.HardDeadline),
+		item.Project,
+		strings.Join(item.Tags, ","),
+		item.Notes,
+		formatInt(item.ParentID),
+		strings.Join(dependsOn, ","),
+		item.Recurrence,
+		formatInt(item.RecursFrom),
+		formatTime(&item.CreatedAt),
+		formatTime(item.ModifiedAt),
+		formatTime(item.CompletedAt),
+		formatTime(item.ArchivedAt),
+	}
+}
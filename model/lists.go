@@ -0,0 +1,127 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultListName is the list todos go to unless another one is chosen. It
+// is kept in todos.json, the file togo used before it had named lists.
+const DefaultListName = "todos"
+
+var listNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateListName checks that name can be used for a list: letters, digits,
+// "-" and "_", starting with a letter or digit.
+func ValidateListName(name string) error {
+	if !listNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid list name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// ListNames returns the lists in the data directory stored in files with the
+// given extension, e.g. ".json", sorted by name.
+func ListNames(ext string) ([]string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dataDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if entry.Type().IsRegular() && name != entry.Name() && ValidateListName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// listFiles returns the files in the data directory that belong to the list
+// name in any storage backend, including locks and backups.
+func listFiles(name string) ([]string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		file := entry.Name()
+		for _, ext := range []string{".json", ".db"} {
+			if file == name+ext || strings.HasPrefix(file, name+ext+".") || strings.HasPrefix(file, name+ext+"-") {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// RenameList renames every file of the list from to to. It refuses if a list
+// called to already exists.
+func RenameList(from, to string) error {
+	if err := ValidateListName(to); err != nil {
+		return err
+	}
+	existing, err := listFiles(to)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("a list called %q already exists", to)
+	}
+	files, err := listFiles(from)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no list called %q", from)
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		renamed := to + strings.TrimPrefix(file, from)
+		if err := os.Rename(filepath.Join(dataDir, file), filepath.Join(dataDir, renamed)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteList removes every file of the list name.
+func DeleteList(name string) error {
+	files, err := listFiles(name)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no list called %q", name)
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(dataDir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// LocalFileName is the file a project keeps its own todo list in,
+	// typically at the root of its repository.
+	LocalFileName = ".togo.json"
+	// LocalDirName is a directory a project keeps its own todo lists in. It
+	// works like the data directory, one file per list.
+	LocalDirName = ".togo"
+	// ProjectsDir is the directory in the user's data directory that keeps
+	// the backups, journals and locks of project lists, out of the
+	// projects' repositories.
+	ProjectsDir = "projects"
+)
+
+// FindLocal looks for a .togo.json file or a .togo directory in dir and then
+// in each of its parents, the way git finds .git, and returns the path of the
+// first one found, or "" if there is none. The home directory is skipped so
+// that a ~/.togo directory cannot stand in for the user's own lists.
+func FindLocal(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	home, _ := os.UserHomeDir()
+	for {
+		if dir != home {
+			if info, err := os.Stat(filepath.Join(dir, LocalFileName)); err == nil && info.Mode().IsRegular() {
+				return filepath.Join(dir, LocalFileName), nil
+			}
+			if info, err := os.Stat(filepath.Join(dir, LocalDirName)); err == nil && info.IsDir() {
+				return filepath.Join(dir, LocalDirName), nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
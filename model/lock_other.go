@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package model
+
+import "os"
+
+// Platforms without a supported file locking call rely on the on-disk
+// change detection in Save alone.
+
+func tryLock(f *os.File) error {
+	return nil
+}
+
+func unlock(f *os.File) {}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// snapshot remembers the file a TodoList was loaded from, so that Save can
+// tell whether another process changed it in the meantime.
+type snapshot struct {
+	exists bool
+	hash   [sha256.Size]byte
+	// data is the file contents, kept for BackupStore.
+	data  []byte
+	todos map[string]Todo
+	// revision is the save counter of stores that keep one.
+	revision int64
+}
+
+// newSnapshot records data, the raw file contents (nil if there was no file),
+// and the todos it held once loaded.
+func newSnapshot(data []byte, todos []Todo) *snapshot {
+	s := &snapshot{exists: data != nil, data: data, todos: make(map[string]Todo, len(todos))}
+	if data != nil {
+		s.hash = sha256.Sum256(data)
+	}
+	for _, todo := range todos {
+		s.todos[todo.UUID] = todo
+	}
+	return s
+}
+
+// matches reports whether data, the current file contents (nil if there is
+// no file), is still what the snapshot was taken of.
+func (s *snapshot) matches(data []byte) bool {
+	if s == nil {
+		return data == nil
+	}
+	if data == nil || !s.exists {
+		return data == nil && !s.exists
+	}
+	return sha256.Sum256(data) == s.hash
+}
+
+// ConflictError is returned by Save when the same todos were changed both
+// in memory and by another togo process since the list was loaded.
+type ConflictError struct {
+	Titles []string
+	// SavedTo is where the unsaved in-memory version was written instead.
+	SavedTo string
+}
+
+func (e *ConflictError) Error() string {
+	msg := fmt.Sprintf("todos changed by another togo process since they were loaded: %s", strings.Join(e.Titles, ", "))
+	if e.SavedTo != "" {
+		msg += fmt.Sprintf(" (your version was saved to %s)", e.SavedTo)
+	}
+	return msg
+}
+
+// mergeFrom folds the changes made in tl since it was loaded into theirs, the
+// list currently on disk, and makes the result tl's contents. Todos are
+// matched by UUID and a todo changed on only one side keeps that change;
+// todos added on either side are kept, with tl's new todos getting fresh
+// short IDs. If a todo was changed or deleted on both sides nothing is
+// merged and a *ConflictError lists them.
+func (tl *TodoList) mergeFrom(theirs *TodoList) error {
+	base := map[string]Todo{}
+	if tl.loaded != nil {
+		base = tl.loaded.todos
+	}
+	ours := make(map[string]bool, len(tl.Todos))
+	var conflicts []string
+
+	// Work out the ID each of our todos gets in the merged list first, so
+	// links between todos can be carried over.
+	ids := make(map[int]int, len(tl.Todos))
+	nextID := theirs.NextID
+	for _, todo := range tl.Todos {
+		ours[todo.UUID] = true
+		if their := theirs.GetTodoByUUID(todo.UUID); their != nil {
+			ids[todo.ID] = their.ID
+		} else if _, known := base[todo.UUID]; !known {
+			ids[todo.ID] = nextID
+			nextID++
+		}
+	}
+	theirs.NextID = nextID
+
+	for _, todo := range tl.Todos {
+		old, known := base[todo.UUID]
+		changed := !known || !todo.ModifiedAt.Equal(old.ModifiedAt)
+		their := theirs.GetTodoByUUID(todo.UUID)
+		switch {
+		case their == nil && !known:
+			theirs.Todos = append(theirs.Todos, remapTodo(todo, ids))
+		case their == nil:
+			// Deleted by the other process.
+			if changed {
+				conflicts = append(conflicts, fmt.Sprintf("%q (deleted elsewhere)", todo.Title))
+			}
+		case !changed:
+		case !known || !their.ModifiedAt.Equal(old.ModifiedAt):
+			conflicts = append(conflicts, fmt.Sprintf("%q", todo.Title))
+		default:
+			*their = remapTodo(todo, ids)
+		}
+	}
+
+	var deleted []int
+	for uuid, old := range base {
+		if ours[uuid] {
+			continue
+		}
+		if their := theirs.GetTodoByUUID(uuid); their != nil {
+			if !their.ModifiedAt.Equal(old.ModifiedAt) {
+				conflicts = append(conflicts, fmt.Sprintf("%q (changed elsewhere)", their.Title))
+				continue
+			}
+			deleted = append(deleted, their.ID)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Titles: conflicts}
+	}
+
+	theirs.rebuildIndex()
+	for _, id := range deleted {
+		theirs.Delete(id)
+	}
+	tl.Todos = theirs.Todos
+	tl.NextID = theirs.NextID
+	tl.rebuildIndex()
+	return nil
+}
+
+// remapTodo returns a copy of todo with its ID and links translated by ids.
+// Links to todos missing from ids are dropped.
+func remapTodo(todo Todo, ids map[int]int) Todo {
+	todo.ID = ids[todo.ID]
+	todo.ParentID = ids[todo.ParentID]
+	todo.RecursFrom = ids[todo.RecursFrom]
+	deps := todo.DependsOn
+	todo.DependsOn = nil
+	for _, dep := range deps {
+		if id, ok := ids[dep]; ok && id != 0 {
+			todo.DependsOn = append(todo.DependsOn, id)
+		}
+	}
+	return todo
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Priority ranks how much a todo matters. The zero value means no priority.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// ParsePriority accepts none/low/medium/high, their first letters (H/M/L)
+// and the empty string, all case-insensitively.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "n", "none":
+		return PriorityNone, nil
+	case "l", "low":
+		return PriorityLow, nil
+	case "m", "med", "medium":
+		return PriorityMedium, nil
+	case "h", "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNone, fmt.Errorf("invalid priority: %s. Use H, M, L or none", s)
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "Low"
+	case PriorityMedium:
+		return "Medium"
+	case PriorityHigh:
+		return "High"
+	}
+	return "None"
+}
+
+// Short returns the one-letter form used in the table, or "" for no priority.
+func (p Priority) Short() string {
+	if p == PriorityNone {
+		return ""
+	}
+	return p.String()[:1]
+}
+
+// Next cycles none → low → medium → high → none.
+func (p Priority) Next() Priority {
+	return (p + 1) % (PriorityHigh + 1)
+}
+
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(p.String()))
+}
+
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// SetPriority changes the priority of a todo.
+func (tl *TodoList) SetPriority(id int, priority Priority) bool {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return false
+	}
+	tl.Todos[idx].Priority = priority
+	tl.touch(idx)
+	return true
+}
+
+// SortByPriority returns a copy of todos ordered from high to no priority,
+// keeping the existing order among todos of equal priority.
+func SortByPriority(todos []Todo) []Todo {
+	sorted := make([]Todo, len(todos))
+	copy(sorted, todos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return sorted
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+const projectQualifier = "project:"
+
+// ProjectNode is one level of the project hierarchy, e.g. "api" in
+// "work.api.auth". Counts cover the node and everything below it.
+type ProjectNode struct {
+	Name      string
+	Path      string
+	Pending   int
+	Completed int
+	Children  []*ProjectNode
+}
+
+// ParseProject splits a project:name qualifier out of a title, e.g.
+// "Fix login project:work.api" becomes "Fix login" in project "work.api".
+// When several qualifiers are given the last one wins.
+func ParseProject(title string) (string, string) {
+	var words []string
+	project := ""
+	for _, word := range strings.Fields(title) {
+		if len(word) > len(projectQualifier) && strings.HasPrefix(strings.ToLower(word), projectQualifier) {
+			project = NormalizeProject(word[len(projectQualifier):])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), project
+}
+
+// NormalizeProject lowercases a project path and drops empty segments, so
+// "Work..API." becomes "work.api".
+func NormalizeProject(project string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.ToLower(strings.TrimSpace(project)), ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// InProject reports whether the todo belongs to project or one of its sub-projects.
+func (t Todo) InProject(project string) bool {
+	project = NormalizeProject(project)
+	if project == "" {
+		return true
+	}
+	return t.Project == project || strings.HasPrefix(t.Project, project+".")
+}
+
+// FilterByProject returns the todos in the project subtree, or todos
+// unchanged if project is empty.
+func FilterByProject(todos []Todo, project string) []Todo {
+	if NormalizeProject(project) == "" {
+		return todos
+	}
+	var filtered []Todo
+	for _, todo := range todos {
+		if todo.InProject(project) {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered
+}
+
+// GetTodosByProject returns every todo in the project subtree.
+func (tl *TodoList) GetTodosByProject(project string) []Todo {
+	return FilterByProject(tl.Todos, project)
+}
+
+// GetProjects returns every project path in use, sorted alphabetically.
+func (tl *TodoList) GetProjects() []string {
+	seen := make(map[string]bool)
+	var projects []string
+	for _, todo := range tl.Todos {
+		if todo.Project != "" && !seen[todo.Project] {
+			seen[todo.Project] = true
+			projects = append(projects, todo.Project)
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+// ProjectTree builds the project hierarchy from the active todos, with
+// pending and completed counts rolled up to every ancestor.
+func (tl *TodoList) ProjectTree() []*ProjectNode {
+	root := &ProjectNode{}
+	for _, todo := range tl.GetActiveTodos() {
+		if todo.Project == "" {
+			continue
+		}
+		node := root
+		for _, segment := range strings.Split(todo.Project, ".") {
+			node = node.child(segment)
+			if todo.Completed {
+				node.Completed++
+			} else {
+				node.Pending++
+			}
+		}
+	}
+	root.sort()
+	return root.Children
+}
+
+func (n *ProjectNode) child(name string) *ProjectNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	path := name
+	if n.Path != "" {
+		path = n.Path + "." + name
+	}
+	c := &ProjectNode{Name: name, Path: path}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (n *ProjectNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
@@ -0,0 +1,531 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query selects todos with a filter expression such as
+//
+//	status:pending due:<3d +backend -blocked title~"deploy"
+//
+// Terms next to each other must all match; "or", "and", "not" and
+// parentheses combine them otherwise. A term is one of
+//
+//	word, "some words"   the title contains the text
+//	+tag, -tag           the todo has, or doesn't have, the tag; +blocked
+//	                     and +overdue also match todos in that state
+//	field:value          the field matches the value, see below
+//	field~pattern        the field matches the regular expression
+//	-field:value         the field doesn't match the value
+//
+// The fields are status (pending, completed, blocked, archived, active),
+// priority (none, low, medium, high), project (the project or a
+// sub-project), tag, title and notes (contain the text), id, uuid (a
+// prefix), due and the times a todo was created, modified, completed and
+// archived. Priorities and times can be compared with <, <=, > and >=:
+// due:<3d is due within three days, created:>=1w was created in the last
+// week and due:2026-01-15 is due that day. due:none has no deadline.
+type Query struct {
+	text     string
+	match    matcher
+	archived bool
+}
+
+type matcher func(tl *TodoList, todo Todo) bool
+
+// QueryError is a query that could not be parsed, pointing at the column
+// where parsing failed.
+type QueryError struct {
+	Query string
+	// Column is where the problem is, counting characters from 1.
+	Column int
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// Pointer returns the query with a caret under the column of the error on
+// the line below it.
+func (e *QueryError) Pointer() string {
+	return e.Query + "\n" + strings.Repeat(" ", e.Column-1) + "^"
+}
+
+// ParseQuery parses a filter expression. An empty query matches every todo.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{text: text, tokens: tokens}
+	if len(tokens) == 0 {
+		return &Query{text: text, match: func(*TodoList, Todo) bool { return true }}, nil
+	}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, p.errorAt(tok.col, "unexpected %q", tok.text)
+	}
+	return &Query{text: text, match: match, archived: p.archived}, nil
+}
+
+// String returns the query as it was written.
+func (q *Query) String() string {
+	return q.text
+}
+
+// Archived reports whether the query looks at whether todos are archived,
+// e.g. status:archived, so that archived todos should be searched too.
+func (q *Query) Archived() bool {
+	return q.archived
+}
+
+// Match reports whether todo, one of the todos of tl, matches the query.
+func (q *Query) Match(tl *TodoList, todo Todo) bool {
+	return q.match(tl, todo)
+}
+
+// Filter returns the todos of tl among todos that match the query.
+func (q *Query) Filter(tl *TodoList, todos []Todo) []Todo {
+	var filtered []Todo
+	for _, todo := range todos {
+		if q.match(tl, todo) {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered
+}
+
+// queryToken is a parenthesis or a term, with the column it starts at.
+type queryToken struct {
+	text string
+	col  int
+}
+
+// lexQuery splits a query into parentheses and terms. Quoted text, which
+// may hold spaces and parentheses, stays part of its term.
+func lexQuery(text string) ([]queryToken, error) {
+	runes := []rune(text)
+	var tokens []queryToken
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{text: string(r), col: i + 1})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+				if runes[i] == '"' {
+					quote := i
+					for i++; i < len(runes) && runes[i] != '"'; i++ {
+					}
+					if i == len(runes) {
+						return nil, &QueryError{Query: text, Column: quote + 1, Msg: "unterminated quote"}
+					}
+				}
+				i++
+			}
+			tokens = append(tokens, queryToken{text: string(runes[start:i]), col: start + 1})
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	text     string
+	tokens   []queryToken
+	pos      int
+	archived bool
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// keyword reports whether the next token is the keyword word.
+func (p *queryParser) keyword(word string) bool {
+	tok := p.peek()
+	return tok != nil && strings.EqualFold(tok.text, word)
+}
+
+func (p *queryParser) errorAt(col int, format string, args ...any) error {
+	return &QueryError{Query: p.text, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// end returns the column just past the query, for errors at its end.
+func (p *queryParser) end() int {
+	return len([]rune(p.text)) + 1
+}
+
+func (p *queryParser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tl *TodoList, todo Todo) bool { return l(tl, todo) || right(tl, todo) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (matcher, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.keyword("and") {
+			p.pos++
+		} else if tok := p.peek(); tok == nil || tok.text == ")" || p.keyword("or") {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tl *TodoList, todo Todo) bool { return l(tl, todo) && right(tl, todo) }
+	}
+}
+
+func (p *queryParser) parseNot() (matcher, error) {
+	if p.keyword("not") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(tl *TodoList, todo Todo) bool { return !inner(tl, todo) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (matcher, error) {
+	tok := p.peek()
+	switch {
+	case tok == nil:
+		return nil, p.errorAt(p.end(), "expected a filter")
+	case tok.text == ")":
+		return nil, p.errorAt(tok.col, "unexpected )")
+	case tok.text == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.text != ")" {
+			return nil, p.errorAt(tok.col, "missing ) for this (")
+		}
+		p.pos++
+		return inner, nil
+	case strings.EqualFold(tok.text, "and") || strings.EqualFold(tok.text, "or"):
+		return nil, p.errorAt(tok.col, "expected a filter before %q", tok.text)
+	}
+	p.pos++
+	return p.parseTerm(*tok)
+}
+
+// queryFieldPattern splits field terms into the field, the operator and the
+// value, e.g. due:<3d.
+var queryFieldPattern = regexp.MustCompile(`^(?i)([a-z_]+)(:<=|:>=|:<|:>|:=|:|~)(.*)$`)
+
+func (p *queryParser) parseTerm(tok queryToken) (matcher, error) {
+	text := tok.text
+	negate := false
+	if strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-") {
+		name := text[1:]
+		if m := queryFieldPattern.FindStringSubmatch(name); m != nil && text[0] == '-' {
+			negate = true
+			text = name
+		} else {
+			if name == "" || strings.ContainsAny(name, `:~"`) {
+				return nil, p.errorAt(tok.col+1, "expected a tag name after %c", text[0])
+			}
+			match := tagMatcher(name)
+			if text[0] == '-' {
+				return func(tl *TodoList, todo Todo) bool { return !match(tl, todo) }, nil
+			}
+			return match, nil
+		}
+	}
+
+	m := queryFieldPattern.FindStringSubmatch(text)
+	if m == nil || strings.HasPrefix(text, `"`) {
+		value := unquote(text)
+		return func(tl *TodoList, todo Todo) bool {
+			return strings.Contains(strings.ToLower(todo.Title), strings.ToLower(value))
+		}, nil
+	}
+	field, op, value := strings.ToLower(m[1]), strings.TrimPrefix(m[2], ":"), unquote(m[3])
+	col := tok.col + len([]rune(text[:len(text)-len(m[3])]))
+	if negate {
+		col++
+	}
+	match, err := p.fieldMatcher(tok.col, col, field, op, value)
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		return func(tl *TodoList, todo Todo) bool { return !match(tl, todo) }, nil
+	}
+	return match, nil
+}
+
+// unquote removes the quotes around a value or a term.
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
+
+// tagMatcher matches todos with tag. The tags blocked and overdue also
+// match todos in that state.
+func tagMatcher(tag string) matcher {
+	tag = NormalizeTag(tag)
+	return func(tl *TodoList, todo Todo) bool {
+		switch {
+		case todo.HasTag(tag):
+			return true
+		case tag == "blocked":
+			return !todo.Completed && tl.IsBlocked(todo.ID)
+		case tag == "overdue":
+			return isOverdue(todo)
+		}
+		return false
+	}
+}
+
+func isOverdue(todo Todo) bool {
+	return !todo.Completed && todo.Deadline != nil && todo.Deadline.Before(time.Now())
+}
+
+// fieldMatcher returns the matcher for field op value. fieldCol and
+// valueCol are where the field and the value start, for errors.
+func (p *queryParser) fieldMatcher(fieldCol, valueCol int, field, op, value string) (matcher, error) {
+	ordered := op == "<" || op == "<=" || op == ">" || op == ">="
+	switch field {
+	case "title", "notes", "project":
+		if ordered {
+			return nil, p.errorAt(valueCol-len(op), "%s can't be compared with %s", field, op)
+		}
+		get := func(todo Todo) string {
+			switch field {
+			case "title":
+				return todo.Title
+			case "notes":
+				return todo.Notes
+			}
+			return todo.Project
+		}
+		if op == "~" {
+			re, err := regexp.Compile("(?i)" + value)
+			if err != nil {
+				return nil, p.errorAt(valueCol, "invalid pattern: %v", err)
+			}
+			return func(tl *TodoList, todo Todo) bool { return re.MatchString(get(todo)) }, nil
+		}
+		if field == "project" {
+			if strings.EqualFold(value, "none") {
+				return func(tl *TodoList, todo Todo) bool { return todo.Project == "" }, nil
+			}
+			return func(tl *TodoList, todo Todo) bool { return todo.InProject(value) }, nil
+		}
+		value = strings.ToLower(value)
+		return func(tl *TodoList, todo Todo) bool { return strings.Contains(strings.ToLower(get(todo)), value) }, nil
+	}
+	if op == "~" {
+		return nil, p.errorAt(valueCol-1, "~ only works on title, notes and project")
+	}
+
+	switch field {
+	case "status", "is":
+		if ordered {
+			return nil, p.errorAt(valueCol-len(op), "status can't be compared with %s", op)
+		}
+		switch strings.ToLower(value) {
+		case "pending", "open":
+			return func(tl *TodoList, todo Todo) bool { return !todo.Completed }, nil
+		case "completed", "done":
+			return func(tl *TodoList, todo Todo) bool { return todo.Completed }, nil
+		case "blocked":
+			return func(tl *TodoList, todo Todo) bool { return !todo.Completed && tl.IsBlocked(todo.ID) }, nil
+		case "overdue":
+			return func(tl *TodoList, todo Todo) bool { return isOverdue(todo) }, nil
+		case "archived":
+			p.archived = true
+			return func(tl *TodoList, todo Todo) bool { return todo.Archived }, nil
+		case "active":
+			p.archived = true
+			return func(tl *TodoList, todo Todo) bool { return !todo.Archived }, nil
+		}
+		return nil, p.errorAt(valueCol, "unknown status %q, use pending, completed, blocked, overdue, archived or active", value)
+	case "tag", "tags":
+		if ordered {
+			return nil, p.errorAt(valueCol-len(op), "tags can't be compared with %s", op)
+		}
+		if strings.EqualFold(value, "none") {
+			return func(tl *TodoList, todo Todo) bool { return len(todo.Tags) == 0 }, nil
+		}
+		return tagMatcher(value), nil
+	case "priority", "pri":
+		priority, err := ParsePriority(value)
+		if err != nil || value == "" {
+			return nil, p.errorAt(valueCol, "unknown priority %q, use none, low, medium or high", value)
+		}
+		return func(tl *TodoList, todo Todo) bool { return compare(int(todo.Priority), int(priority), op) }, nil
+	case "id":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, p.errorAt(valueCol, "expected a number, not %q", value)
+		}
+		return func(tl *TodoList, todo Todo) bool { return compare(todo.ID, id, op) }, nil
+	case "uuid":
+		if ordered {
+			return nil, p.errorAt(valueCol-len(op), "uuid can't be compared with %s", op)
+		}
+		value = strings.ToLower(value)
+		return func(tl *TodoList, todo Todo) bool { return value != "" && strings.HasPrefix(todo.UUID, value) }, nil
+	case "due", "deadline", "created", "modified", "completed", "archived":
+		if field == "archived" {
+			p.archived = true
+		}
+		return p.timeMatcher(valueCol, field, op, value)
+	}
+	return nil, p.errorAt(fieldCol, "unknown field %q", field)
+}
+
+// compare reports whether a op b, where an empty op means equal.
+func compare(a, b int, op string) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return a == b
+}
+
+// timeMatcher matches one of the moments recorded on a todo. Deadlines
+// take relative times such as 3d as from now, the other fields as ago.
+func (p *queryParser) timeMatcher(valueCol int, field, op, value string) (matcher, error) {
+	get := func(todo Todo) (time.Time, bool) {
+		switch field {
+		case "due", "deadline":
+			if todo.Deadline == nil {
+				return time.Time{}, false
+			}
+			return *todo.Deadline, true
+		case "created":
+			return todo.Time(TimeCreated)
+		case "modified":
+			return todo.Time(TimeModified)
+		case "completed":
+			return todo.Time(TimeCompleted)
+		}
+		return todo.Time(TimeArchived)
+	}
+	switch strings.ToLower(value) {
+	case "none":
+		return func(tl *TodoList, todo Todo) bool { _, ok := get(todo); return !ok }, nil
+	case "any":
+		return func(tl *TodoList, todo Todo) bool { _, ok := get(todo); return ok }, nil
+	}
+
+	future := field == "due" || field == "deadline"
+	at, day, err := parseQueryTime(value, future)
+	if err != nil {
+		return nil, p.errorAt(valueCol, "%v", err)
+	}
+	if op == "" || op == "=" {
+		switch {
+		case day:
+			// A day matches any time on it.
+			next := at.AddDate(0, 0, 1)
+			return func(tl *TodoList, todo Todo) bool {
+				t, ok := get(todo)
+				return ok && !t.Before(at) && t.Before(next)
+			}, nil
+		case future:
+			op = "<="
+		default:
+			op = ">="
+		}
+	}
+	return func(tl *TodoList, todo Todo) bool {
+		t, ok := get(todo)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "<":
+			return t.Before(at)
+		case "<=":
+			return !t.After(at)
+		case ">":
+			return t.After(at)
+		}
+		return !t.Before(at)
+	}, nil
+}
+
+// parseQueryTime parses a moment in a query: now, today, tomorrow,
+// yesterday, a date, or an offset like 3d, 12h or 2w, from now when future
+// is set and ago otherwise. day is set for values naming a whole day.
+func parseQueryTime(value string, future bool) (at time.Time, day bool, err error) {
+	value = strings.ToLower(value)
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "now":
+		return now, false, nil
+	case "today":
+		return midnight, true, nil
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), true, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), true, nil
+	}
+	if m := regexp.MustCompile(`^(\d+)([mhdw])$`).FindStringSubmatch(value); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if !future {
+			n = -n
+		}
+		switch m[2] {
+		case "m":
+			return now.Add(time.Duration(n) * time.Minute), false, nil
+		case "h":
+			return now.Add(time.Duration(n) * time.Hour), false, nil
+		case "d":
+			return now.AddDate(0, 0, n), false, nil
+		}
+		return now.AddDate(0, 0, 7*n), false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time %q, use e.g. 3d, 2w, today or 2026-01-15", value)
+}
@@ -0,0 +1,219 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type recurrenceKind int
+
+const (
+	recurInterval recurrenceKind = iota
+	recurWeekdays
+	recurWeekday
+	recurMonthDay
+)
+
+// Recurrence is a parsed repeat rule such as "1w", "weekday" or "monthly on 1".
+type Recurrence struct {
+	kind    recurrenceKind
+	count   int
+	unit    string
+	weekday time.Weekday
+	day     int
+}
+
+var (
+	intervalPattern = regexp.MustCompile(`^(\d+)\s*([dwmy])$`)
+	monthDayPattern = regexp.MustCompile(`^monthly on (\d{1,2})$`)
+)
+
+// ParseRecurrence parses repeat rules: intervals like "2d", "1w", "3m"
+// (months) and "1y", the words daily/weekly/monthly/yearly, "weekday" for
+// Monday to Friday, a day name like "monday", and "monthly on 15".
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.Join(strings.Fields(strings.ToLower(rule)), " ")
+	rule = strings.TrimPrefix(rule, "every ")
+	switch rule {
+	case "daily", "day":
+		return &Recurrence{kind: recurInterval, count: 1, unit: "d"}, nil
+	case "weekly", "week":
+		return &Recurrence{kind: recurInterval, count: 1, unit: "w"}, nil
+	case "monthly", "month":
+		return &Recurrence{kind: recurInterval, count: 1, unit: "m"}, nil
+	case "yearly", "year":
+		return &Recurrence{kind: recurInterval, count: 1, unit: "y"}, nil
+	case "weekday", "weekdays":
+		return &Recurrence{kind: recurWeekdays}, nil
+	}
+	if matches := intervalPattern.FindStringSubmatch(rule); matches != nil {
+		count, err := strconv.Atoi(matches[1])
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid repeat interval: %s", rule)
+		}
+		return &Recurrence{kind: recurInterval, count: count, unit: matches[2]}, nil
+	}
+	if matches := monthDayPattern.FindStringSubmatch(rule); matches != nil {
+		day, _ := strconv.Atoi(matches[1])
+		if day < 1 || day > 31 {
+			return nil, fmt.Errorf("invalid day of month: %d", day)
+		}
+		return &Recurrence{kind: recurMonthDay, day: day}, nil
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if rule == name || rule == name[:3] {
+			return &Recurrence{kind: recurWeekday, weekday: weekday}, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to parse repeat rule: %s. Use rules like '1d', '1w', 'weekday', 'monday' or 'monthly on 1'", rule)
+}
+
+// Next returns the first occurrence strictly after from, keeping its time of day.
+func (r Recurrence) Next(from time.Time) time.Time {
+	switch r.kind {
+	case recurWeekdays:
+		next := from.AddDate(0, 0, 1)
+		for next.Weekday() == time.Saturday || next.Weekday() == time.Sunday {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	case recurWeekday:
+		days := (int(r.weekday) - int(from.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return from.AddDate(0, 0, days)
+	case recurMonthDay:
+		for months := 0; ; months++ {
+			year, month, _ := from.Date()
+			first := time.Date(year, month+time.Month(months), 1, from.Hour(), from.Minute(), 0, 0, from.Location())
+			day := r.day
+			if last := first.AddDate(0, 1, -1).Day(); day > last {
+				day = last
+			}
+			if next := first.AddDate(0, 0, day-1); next.After(from) {
+				return next
+			}
+		}
+	}
+	switch r.unit {
+	case "w":
+		return from.AddDate(0, 0, 7*r.count)
+	case "m":
+		return from.AddDate(0, r.count, 0)
+	case "y":
+		return from.AddDate(r.count, 0, 0)
+	}
+	return from.AddDate(0, 0, r.count)
+}
+
+func (r Recurrence) String() string {
+	switch r.kind {
+	case recurWeekdays:
+		return "weekday"
+	case recurWeekday:
+		return strings.ToLower(r.weekday.String())
+	case recurMonthDay:
+		return fmt.Sprintf("monthly on %d", r.day)
+	}
+	return fmt.Sprintf("%d%s", r.count, r.unit)
+}
+
+// FormatRecurrence describes a stored rule for display, e.g. "every 1w" or
+// "monthly on 1".
+func FormatRecurrence(rule string) string {
+	if rule == "" || strings.HasPrefix(rule, "monthly") {
+		return rule
+	}
+	return "every " + rule
+}
+
+// SetRecurrence makes a todo repeat by rule. An empty rule stops it repeating.
+func (tl *TodoList) SetRecurrence(id int, rule string) error {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return fmt.Errorf("todo %d not found", id)
+	}
+	if rule == "" {
+		tl.Todos[idx].Recurrence = ""
+		tl.touch(idx)
+		return nil
+	}
+	recurrence, err := ParseRecurrence(rule)
+	if err != nil {
+		return err
+	}
+	tl.Todos[idx].Recurrence = recurrence.String()
+	tl.touch(idx)
+	return nil
+}
+
+// NextDue returns when the next instance of a recurring todo will be due,
+// counted from its deadline or, without one, from now.
+func (t Todo) NextDue() *time.Time {
+	recurrence, err := ParseRecurrence(t.Recurrence)
+	if t.Recurrence == "" || err != nil {
+		return nil
+	}
+	base := time.Now()
+	if t.Deadline != nil {
+		base = *t.Deadline
+	}
+	next := recurrence.Next(base)
+	return &next
+}
+
+// GetNextOccurrence returns the instance that was spawned when id was completed.
+func (tl *TodoList) GetNextOccurrence(id int) *Todo {
+	for i, todo := range tl.Todos {
+		if todo.RecursFrom == id {
+			return &tl.Todos[i]
+		}
+	}
+	return nil
+}
+
+// GetOccurrenceHistory returns the earlier instances of a recurring todo,
+// most recent first.
+func (tl *TodoList) GetOccurrenceHistory(id int) []Todo {
+	var history []Todo
+	todo := tl.GetTodoByID(id)
+	for todo != nil && todo.RecursFrom != 0 {
+		todo = tl.GetTodoByID(todo.RecursFrom)
+		if todo != nil {
+			history = append(history, *todo)
+		}
+	}
+	return history
+}
+
+// spawnNextOccurrence adds the next instance of a recurring todo that was
+// just completed, with its deadline moved on by the rule. Completing the
+// same instance again after reopening it does not spawn a second one.
+func (tl *TodoList) spawnNextOccurrence(id int) *Todo {
+	todo := tl.GetTodoByID(id)
+	if todo == nil || todo.Recurrence == "" || tl.GetNextOccurrence(id) != nil {
+		return nil
+	}
+	next := *todo
+	next.ID = tl.NextID
+	next.UUID = newUUID()
+	next.Completed = false
+	next.Archived = false
+	next.CreatedAt = time.Now()
+	next.ModifiedAt = next.CreatedAt
+	next.CompletedAt = nil
+	next.ArchivedAt = nil
+	next.Deadline = todo.NextDue()
+	next.RecursFrom = todo.ID
+	next.Tags = append([]string(nil), todo.Tags...)
+	next.DependsOn = nil
+	tl.Todos = append(tl.Todos, next)
+	tl.TodoByID[next.ID] = len(tl.Todos) - 1
+	tl.NextID++
+	return &tl.Todos[len(tl.Todos)-1]
+}
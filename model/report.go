@@ -0,0 +1,237 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is a saved view of a todo list: the todos matching a query, sorted
+// and cut to a limit, showing some of their columns.
+type Report struct {
+	Description string `json:"description,omitempty"`
+	// Filter is a query, see Query. Archived todos are left out unless the
+	// query looks at them.
+	Filter string `json:"filter,omitempty"`
+	// Sort lists the columns to sort by, most significant first, each
+	// prefixed with - to sort it in descending order.
+	Sort []string `json:"sort,omitempty"`
+	// Columns are the columns shown, see Columns.
+	Columns []string `json:"columns,omitempty"`
+	// Limit is how many todos are shown at most, or 0 for all of them.
+	Limit int `json:"limit,omitempty"`
+}
+
+// DefaultReportColumns are the columns of a report that doesn't list any.
+var DefaultReportColumns = []string{"id", "priority", "due", "title", "project", "tags"}
+
+// BuiltinReports are the reports togo knows without any configuration.
+var BuiltinReports = map[string]Report{
+	"next": {
+		Description: "What to work on next: unblocked pending todos, most important first",
+		Filter:      "status:pending -blocked",
+		Sort:        []string{"-priority", "due", "id"},
+		Limit:       10,
+	},
+	"overdue": {
+		Description: "Pending todos past their deadline",
+		Filter:      "+overdue",
+		Sort:        []string{"due"},
+		Columns:     []string{"id", "priority", "due", "title", "project"},
+	},
+	"recent": {
+		Description: "Todos changed in the last week, newest first",
+		Filter:      "modified:>=1w",
+		Sort:        []string{"-modified"},
+		Columns:     []string{"id", "modified", "status", "title"},
+		Limit:       20,
+	},
+	"waiting": {
+		Description: "Pending todos blocked by unfinished dependencies",
+		Filter:      "+blocked",
+		Sort:        []string{"-priority", "id"},
+		Columns:     []string{"id", "priority", "title", "blocked_by"},
+	},
+}
+
+// Columns are the columns reports can show and sort by, in the order they
+// are listed in help texts.
+var Columns = []string{
+	"id", "uuid", "title", "status", "priority", "due", "project", "tags",
+	"created", "modified", "completed", "archived", "parent", "blocked_by", "notes",
+}
+
+// Validate checks the report's query, sort order and columns.
+func (r Report) Validate() error {
+	if _, err := ParseQuery(r.Filter); err != nil {
+		return fmt.Errorf("filter %q: %w", r.Filter, err)
+	}
+	for _, key := range r.Sort {
+		if !isColumn(strings.TrimPrefix(key, "-")) {
+			return fmt.Errorf("can't sort by %q, use one of %s", key, strings.Join(Columns, ", "))
+		}
+	}
+	for _, column := range r.Columns {
+		if !isColumn(column) {
+			return fmt.Errorf("unknown column %q, use one of %s", column, strings.Join(Columns, ", "))
+		}
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("the limit can't be negative")
+	}
+	return nil
+}
+
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportColumns returns the columns the report shows.
+func (r Report) ReportColumns() []string {
+	if len(r.Columns) == 0 {
+		return DefaultReportColumns
+	}
+	return r.Columns
+}
+
+// Run returns the todos of tl the report shows, in order.
+func (r Report) Run(tl *TodoList) ([]Todo, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	query, _ := ParseQuery(r.Filter)
+	todos := tl.GetActiveTodos()
+	if query.Archived() {
+		todos = tl.Todos
+	}
+	todos = query.Filter(tl, todos)
+	SortTodos(tl, todos, r.Sort)
+	if r.Limit > 0 && len(todos) > r.Limit {
+		todos = todos[:r.Limit]
+	}
+	return todos, nil
+}
+
+// SortTodos sorts todos in place by the columns in keys, each prefixed with
+// - for descending order. Todos without a value for a column, such as todos
+// without a deadline, come last either way.
+func SortTodos(tl *TodoList, todos []Todo, keys []string) {
+	sort.SliceStable(todos, func(i, j int) bool {
+		for _, key := range keys {
+			column := strings.TrimPrefix(key, "-")
+			a, aOK := sortValue(tl, todos[i], column)
+			b, bOK := sortValue(tl, todos[j], column)
+			switch {
+			case aOK != bOK:
+				return aOK
+			case a == b:
+				continue
+			case strings.HasPrefix(key, "-"):
+				return a > b
+			}
+			return a < b
+		}
+		return false
+	})
+}
+
+// sortValue returns a value of the column that sorts the way the column
+// should, and false when the todo has none.
+func sortValue(tl *TodoList, todo Todo, column string) (string, bool) {
+	switch column {
+	case "id", "parent":
+		id := todo.ID
+		if column == "parent" {
+			id = todo.ParentID
+		}
+		return fmt.Sprintf("%010d", id), id != 0
+	case "priority":
+		return strconv.Itoa(int(todo.Priority)), true
+	case "due", "created", "modified", "completed", "archived":
+		t, ok := columnTime(todo, column)
+		return t.UTC().Format(time.RFC3339Nano), ok
+	}
+	value := ColumnValue(tl, todo, column)
+	return strings.ToLower(value), value != ""
+}
+
+func columnTime(todo Todo, column string) (time.Time, bool) {
+	switch column {
+	case "due":
+		if todo.Deadline == nil {
+			return time.Time{}, false
+		}
+		return *todo.Deadline, true
+	case "created":
+		return todo.Time(TimeCreated)
+	case "modified":
+		return todo.Time(TimeModified)
+	case "completed":
+		return todo.Time(TimeCompleted)
+	}
+	return todo.Time(TimeArchived)
+}
+
+// Status returns how the table shows the state of a todo: Completed,
+// Blocked or Pending, followed by (archived) for archived todos.
+func (tl *TodoList) Status(todo Todo) string {
+	status := "Pending"
+	if todo.Completed {
+		status = "Completed"
+	} else if tl.IsBlocked(todo.ID) {
+		status = "Blocked"
+	}
+	if todo.Archived {
+		status += " (archived)"
+	}
+	return status
+}
+
+// ColumnValue returns one column of a todo as text, or "" when the todo has
+// no value for it.
+func ColumnValue(tl *TodoList, todo Todo, column string) string {
+	switch column {
+	case "id":
+		return strconv.Itoa(todo.ID)
+	case "uuid":
+		return todo.UUID
+	case "title":
+		return todo.Title
+	case "status":
+		return tl.Status(todo)
+	case "priority":
+		return todo.Priority.Short()
+	case "due":
+		if todo.Deadline == nil {
+			return ""
+		}
+		return todo.Deadline.Format("2006-01-02 15:04")
+	case "created", "modified", "completed", "archived":
+		if t, ok := columnTime(todo, column); ok {
+			return t.Format("2006-01-02 15:04")
+		}
+		return ""
+	case "project":
+		return todo.Project
+	case "tags":
+		return FormatTags(todo.Tags)
+	case "parent":
+		return formatID(todo.ParentID)
+	case "blocked_by":
+		var titles []string
+		for _, blocker := range tl.Blockers(todo.ID) {
+			titles = append(titles, blocker.Title)
+		}
+		return strings.Join(titles, ", ")
+	case "notes":
+		return shortValue(todo.Notes)
+	}
+	return ""
+}
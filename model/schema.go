@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the todos.json layout this build writes.
+// Bump it together with a new entry in migrations whenever stored data needs
+// upgrading.
+const SchemaVersion = 3
+
+// migration upgrades a todo list from one schema version to the next.
+type migration struct {
+	description string
+	apply       func(tl *TodoList)
+}
+
+// migrations holds the upgrade steps in order: migrations[v] takes a list
+// from version v to v+1. Files written before schema_version existed are
+// version 0.
+var migrations = []migration{
+	{"fill in missing creation times", func(tl *TodoList) {
+		for i := range tl.Todos {
+			if tl.Todos[i].CreatedAt.IsZero() {
+				tl.Todos[i].CreatedAt = time.Now()
+			}
+		}
+	}},
+	{"start modification times at creation", func(tl *TodoList) {
+		for i := range tl.Todos {
+			if tl.Todos[i].ModifiedAt.IsZero() {
+				tl.Todos[i].ModifiedAt = tl.Todos[i].CreatedAt
+			}
+		}
+	}},
+	{"assign UUIDs", func(tl *TodoList) {
+		tl.backfillUUIDs()
+	}},
+}
+
+// NewerSchemaError is returned for data written by a newer version of togo,
+// which this build could not read without losing fields it doesn't know.
+type NewerSchemaError struct {
+	Version int
+}
+
+func (e *NewerSchemaError) Error() string {
+	return fmt.Sprintf("written by a newer version of togo (schema version %d, this version supports up to %d); please upgrade togo", e.Version, SchemaVersion)
+}
+
+// CheckSchemaVersion returns a *NewerSchemaError if data of the given schema
+// version is too new for this build to read.
+func CheckSchemaVersion(version int) error {
+	if version > SchemaVersion {
+		return &NewerSchemaError{Version: version}
+	}
+	return nil
+}
+
+// Migrate upgrades tl step by step to SchemaVersion and returns the version
+// it started from.
+func Migrate(tl *TodoList) (int, error) {
+	from := tl.SchemaVersion
+	if err := CheckSchemaVersion(from); err != nil {
+		return from, err
+	}
+	if from < 0 {
+		return from, fmt.Errorf("invalid schema version %d", from)
+	}
+	for version := from; version < SchemaVersion; version++ {
+		migrations[version].apply(tl)
+		tl.SchemaVersion = version + 1
+	}
+	return from, nil
+}
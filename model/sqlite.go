@@ -0,0 +1,287 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+var _ Store = (*SQLiteStore)(nil)
+
+// sqliteSchema creates the tables of a SQLite store. Every todo is kept whole
+// as JSON in data, so nothing is lost as fields are added; the other columns
+// copy the fields queries filter on. Indexes on the deadline and title were
+// dropped, since nothing looks todos up by them.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS todos (
+	id          INTEGER PRIMARY KEY,
+	uuid        TEXT NOT NULL UNIQUE,
+	title       TEXT NOT NULL,
+	completed   INTEGER NOT NULL,
+	archived    INTEGER NOT NULL,
+	deadline    TEXT,
+	modified_at TEXT NOT NULL,
+	data        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS todos_completed ON todos (completed);
+CREATE INDEX IF NOT EXISTS todos_archived ON todos (archived, completed);
+DROP INDEX IF EXISTS todos_deadline;
+DROP INDEX IF EXISTS todos_title;
+`
+
+// sqliteTime formats times so that they sort correctly as text.
+const sqliteTime = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteStore keeps a todo list in a SQLite database, one row per todo.
+// Saving only writes the todos that changed since the list was loaded.
+type SQLiteStore struct {
+	Path string
+}
+
+// NewSQLiteStore returns a store for the SQLite database at path.
+func NewSQLiteStore(path string) *SQLiteStore {
+	return &SQLiteStore{Path: path}
+}
+
+// DefaultSQLiteStore returns the SQLite store for filename in the data
+// directory.
+func DefaultSQLiteStore(filename string) (*SQLiteStore, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLiteStore(filepath.Join(dataDir, filename)), nil
+}
+
+func (s *SQLiteStore) open() (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return nil, err
+	}
+	// Transactions take the write lock up front, and wait for other togo
+	// processes to finish instead of failing straight away.
+	dsn := "file:" + (&url.URL{Path: s.Path}).EscapedPath() +
+		"?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	// SQLite creates its files with the umask's permissions, so they are
+	// made readable by the user only once they exist.
+	for _, path := range []string{s.Path, s.Path + "-wal", s.Path + "-shm"} {
+		if err := os.Chmod(path, 0600); err != nil && !os.IsNotExist(err) {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Load reads every todo from the database.
+func (s *SQLiteStore) Load() (*TodoList, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	tl, revision, err := loadSQLite(db)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	from, err := Migrate(tl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	tl.loaded = newSnapshot(nil, tl.Todos)
+	tl.loaded.revision = revision
+	tl.markClean()
+	if from < SchemaVersion {
+		// Rewrite every todo in the upgraded form.
+		tl.loaded.todos = map[string]Todo{}
+		if err := s.Save(tl); err != nil {
+			return nil, err
+		}
+	}
+	return tl, nil
+}
+
+type sqlQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// loadSQLite reads the list and the revision it is at. An empty database
+// holds an empty list.
+func loadSQLite(db sqlQuerier) (*TodoList, int64, error) {
+	meta := map[string]string{}
+	rows, err := db.Query(`SELECT key, value FROM meta`)
+	if err != nil {
+		return nil, 0, err
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		meta[key] = value
+	}
+	rows.Close()
+
+	tl := NewTodoList()
+	if len(meta) == 0 {
+		return tl, 0, nil
+	}
+	revision, _ := strconv.ParseInt(meta["revision"], 10, 64)
+	tl.SchemaVersion, _ = strconv.Atoi(meta["schema_version"])
+	tl.NextID, _ = strconv.Atoi(meta["next_id"])
+	if err := CheckSchemaVersion(tl.SchemaVersion); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err = db.Query(`SELECT data FROM todos ORDER BY rowid`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, 0, err
+		}
+		var todo Todo
+		if err := json.Unmarshal([]byte(data), &todo); err != nil {
+			return nil, 0, err
+		}
+		tl.Todos = append(tl.Todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	tl.rebuildIndex()
+	return tl, revision, nil
+}
+
+// Save writes the todos added, changed or deleted since tl was loaded in one
+// transaction. If another process saved in the meantime, its changes are
+// merged in first, and a *ConflictError is returned without saving anything
+// when both changed the same todos.
+func (s *SQLiteStore) Save(tl *TodoList) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var revision int64
+	var value string
+	if err := tx.QueryRow(`SELECT value FROM meta WHERE key = 'revision'`).Scan(&value); err == nil {
+		revision, _ = strconv.ParseInt(value, 10, 64)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	if tl.loaded == nil {
+		tl.loaded = newSnapshot(nil, nil)
+	}
+	written := tl.loaded.todos
+	if revision != tl.loaded.revision {
+		theirs, _, err := loadSQLite(tx)
+		if err != nil {
+			return fmt.Errorf("%s changed since it was loaded and could not be read: %w", s.Path, err)
+		}
+		if _, err := Migrate(theirs); err != nil {
+			return err
+		}
+		written = newSnapshot(nil, theirs.Todos).todos
+		if err := tl.mergeFrom(theirs); err != nil {
+			return err
+		}
+	}
+
+	upsert, err := tx.Prepare(`INSERT INTO todos (id, uuid, title, completed, archived, deadline, modified_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (uuid) DO UPDATE SET id = excluded.id, title = excluded.title,
+			completed = excluded.completed, archived = excluded.archived, deadline = excluded.deadline,
+			modified_at = excluded.modified_at, data = excluded.data`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	// Short IDs may move between todos when merging, so rows whose ID is
+	// taken over are cleared out of the way first.
+	for _, todo := range tl.Todos {
+		if old, ok := written[todo.UUID]; ok && old.ID == todo.ID {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM todos WHERE id = ? AND uuid <> ?`, todo.ID, todo.UUID); err != nil {
+			return err
+		}
+	}
+	current := make(map[string]bool, len(tl.Todos))
+	for _, todo := range tl.Todos {
+		current[todo.UUID] = true
+		if old, ok := written[todo.UUID]; ok && old.ID == todo.ID && old.ModifiedAt.Equal(todo.ModifiedAt) {
+			continue
+		}
+		data, err := json.Marshal(todo)
+		if err != nil {
+			return err
+		}
+		var deadline any
+		if todo.Deadline != nil {
+			deadline = todo.Deadline.UTC().Format(sqliteTime)
+		}
+		if _, err := upsert.Exec(todo.ID, todo.UUID, todo.Title, todo.Completed, todo.Archived,
+			deadline, todo.ModifiedAt.UTC().Format(sqliteTime), string(data)); err != nil {
+			return err
+		}
+	}
+	for uuid := range written {
+		if !current[uuid] {
+			if _, err := tx.Exec(`DELETE FROM todos WHERE uuid = ?`, uuid); err != nil {
+				return err
+			}
+		}
+	}
+
+	revision++
+	meta := map[string]string{
+		"schema_version": strconv.Itoa(SchemaVersion),
+		"next_id":        strconv.Itoa(tl.NextID),
+		"revision":       strconv.FormatInt(revision, 10),
+		"saved_at":       time.Now().UTC().Format(sqliteTime),
+	}
+	for key, value := range meta {
+		if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	tl.SchemaVersion = SchemaVersion
+	tl.loaded = newSnapshot(nil, tl.Todos)
+	tl.loaded.revision = revision
+	return nil
+}
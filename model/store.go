@@ -0,0 +1,248 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is where a todo list lives between runs.
+type Store interface {
+	// Load returns the stored list, or an empty one if nothing is stored yet.
+	Load() (*TodoList, error)
+	// Save stores tl, replacing what was stored before.
+	Save(tl *TodoList) error
+}
+
+var (
+	_ Store = (*JSONFileStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// JSONFileStore keeps a todo list in a single JSON file. It is the default
+// store.
+type JSONFileStore struct {
+	Path string
+	// LockPath is the lock file taken while saving, Path + ".lock" by
+	// default.
+	LockPath string
+}
+
+// NewJSONFileStore returns a store for the JSON file at path.
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{Path: path, LockPath: path + ".lock"}
+}
+
+// DefaultStore returns the JSON file store for filename in the data directory.
+func DefaultStore(filename string) (*JSONFileStore, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONFileStore(filepath.Join(dataDir, filename)), nil
+}
+
+// Load reads the file, upgrading it if it was written with an older schema.
+func (s *JSONFileStore) Load() (*TodoList, error) {
+	data, err := readDataFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		tl := NewTodoList()
+		tl.loaded = newSnapshot(nil, nil)
+		return tl, nil
+	}
+	var tl TodoList
+	if err := json.Unmarshal(data, &tl); err != nil {
+		return nil, err
+	}
+	tl.rebuildIndex()
+	from, err := Migrate(&tl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	tl.loaded = newSnapshot(data, tl.Todos)
+	tl.markClean()
+	if from < SchemaVersion {
+		// Keep the file as it was before upgrading it, and save the upgrade
+		// straight away so it only happens once.
+		backupPath := fmt.Sprintf("%s.v%d.bak", s.Path, from)
+		if err := writeDataFile(backupPath, data); err != nil {
+			return nil, fmt.Errorf("could not back up %s before upgrading it: %w", s.Path, err)
+		}
+		if err := s.Save(&tl); err != nil {
+			return nil, err
+		}
+	}
+	return &tl, nil
+}
+
+// Save replaces the file atomically while holding an advisory lock, so
+// concurrent togo processes never see or write a half-saved file. The lock is
+// only held while saving, not from Load on, since the TUI keeps a list loaded
+// for as long as it is open: changes another process saved since tl was
+// loaded are detected by comparing the file with what was loaded, and merged
+// in first. When the same todos were changed on both sides Save refuses with
+// a *ConflictError and writes tl next to the file instead.
+func (s *JSONFileStore) Save(tl *TodoList) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	lockPath := s.LockPath
+	if lockPath == "" {
+		lockPath = s.Path + ".lock"
+	}
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return err
+	}
+	release, err := acquireLock(lockPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	onDisk, err := readDataFile(s.Path)
+	if err != nil {
+		return err
+	}
+	if !tl.loaded.matches(onDisk) {
+		theirs, err := parseTodoList(onDisk)
+		if err != nil {
+			return fmt.Errorf("%s changed on disk and could not be read: %w", s.Path, err)
+		}
+		if err := tl.mergeFrom(theirs); err != nil {
+			var conflict *ConflictError
+			if errors.As(err, &conflict) {
+				conflictPath := s.Path + ".conflict"
+				if data, err := json.Marshal(tl); err == nil && writeDataFile(conflictPath, data) == nil {
+					conflict.SavedTo = conflictPath
+				}
+			}
+			return err
+		}
+	}
+
+	tl.SchemaVersion = SchemaVersion
+	data, err := json.Marshal(tl)
+	if err != nil {
+		return err
+	}
+	if err := writeDataFile(s.Path, data); err != nil {
+		return err
+	}
+	tl.loaded = newSnapshot(data, tl.Todos)
+	return nil
+}
+
+// parseTodoList decodes a todos file, upgrading it to the current schema.
+// No data at all stands for a file that doesn't exist yet.
+func parseTodoList(data []byte) (*TodoList, error) {
+	if data == nil {
+		return NewTodoList(), nil
+	}
+	var tl TodoList
+	if err := json.Unmarshal(data, &tl); err != nil {
+		return nil, err
+	}
+	tl.rebuildIndex()
+	if _, err := Migrate(&tl); err != nil {
+		return nil, err
+	}
+	tl.markClean()
+	return &tl, nil
+}
+
+// readFileIfExists returns the contents of path, or nil if it doesn't exist.
+func readFileIfExists(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if data == nil && err == nil {
+		data = []byte{}
+	}
+	return data, err
+}
+
+// MemoryStore keeps a todo list in memory, for tests and for callers that
+// don't want to touch the disk. Loaded lists are copies, so changes only
+// show up in the store once saved.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() (*TodoList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tl, err := parseTodoList(s.data)
+	if err != nil {
+		return nil, err
+	}
+	tl.loaded = newSnapshot(s.data, tl.Todos)
+	return tl, nil
+}
+
+func (s *MemoryStore) Save(tl *TodoList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tl.SchemaVersion = SchemaVersion
+	data, err := json.Marshal(tl)
+	if err != nil {
+		return err
+	}
+	s.data = data
+	tl.loaded = newSnapshot(data, tl.Todos)
+	return nil
+}
+
+// CopyStore copies every todo from one store into another, empty, store,
+// keeping IDs and every field as they are. It reads the copy back and fails
+// if anything came out different, and returns the number of todos copied.
+func CopyStore(from, to Store) (int, error) {
+	source, err := from.Load()
+	if err != nil {
+		return 0, err
+	}
+	target, err := to.Load()
+	if err != nil {
+		return 0, err
+	}
+	if len(target.Todos) > 0 {
+		return 0, fmt.Errorf("the destination already holds %d todos", len(target.Todos))
+	}
+	target.Todos = source.Todos
+	target.NextID = source.NextID
+	target.rebuildIndex()
+	// A copy is not an operation to undo.
+	target.markClean()
+	if err := to.Save(target); err != nil {
+		return 0, err
+	}
+
+	copied, err := to.Load()
+	if err != nil {
+		return 0, err
+	}
+	want, err := json.Marshal(source.Todos)
+	if err != nil {
+		return 0, err
+	}
+	got, err := json.Marshal(copied.Todos)
+	if err != nil {
+		return 0, err
+	}
+	if string(want) != string(got) || copied.NextID != source.NextID {
+		return 0, errors.New("the copied todos don't match the originals")
+	}
+	return len(source.Todos), nil
+}
@@ -0,0 +1,146 @@
+package model
+
+import "fmt"
+
+// ChildPolicy says what happens to the subtasks of a todo that is archived or deleted.
+type ChildPolicy int
+
+const (
+	// KeepChildren leaves subtasks alone. On delete they move up one level.
+	KeepChildren ChildPolicy = iota
+	// IncludeChildren applies the action to the whole subtree.
+	IncludeChildren
+)
+
+// SetParent makes id a subtask of parentID. A parentID of 0 turns it back
+// into a top-level todo.
+func (tl *TodoList) SetParent(id, parentID int) error {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return fmt.Errorf("todo %d not found", id)
+	}
+	if parentID != 0 {
+		if tl.findIndexByID(parentID) == -1 {
+			return fmt.Errorf("parent todo %d not found", parentID)
+		}
+		for ancestor := parentID; ancestor != 0; ancestor = tl.GetTodoByID(ancestor).ParentID {
+			if ancestor == id {
+				return fmt.Errorf("todo %d cannot be a subtask of itself or of its own subtasks", id)
+			}
+		}
+	}
+	oldParentID := tl.Todos[idx].ParentID
+	tl.Todos[idx].ParentID = parentID
+	tl.touch(idx)
+	tl.syncParentCompletion(oldParentID)
+	tl.syncParentCompletion(parentID)
+	return nil
+}
+
+// GetChildren returns the direct subtasks of a todo.
+func (tl *TodoList) GetChildren(id int) []Todo {
+	var children []Todo
+	for _, todo := range tl.Todos {
+		if todo.ParentID == id && id != 0 {
+			children = append(children, todo)
+		}
+	}
+	return children
+}
+
+// HasChildren reports whether a todo has any subtasks.
+func (tl *TodoList) HasChildren(id int) bool {
+	for _, todo := range tl.Todos {
+		if todo.ParentID == id && id != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDescendantIDs returns the IDs of every subtask below a todo, depth first.
+func (tl *TodoList) GetDescendantIDs(id int) []int {
+	var ids []int
+	for _, child := range tl.GetChildren(id) {
+		ids = append(ids, child.ID)
+		ids = append(ids, tl.GetDescendantIDs(child.ID)...)
+	}
+	return ids
+}
+
+// Progress returns how many of a todo's direct subtasks are completed.
+func (tl *TodoList) Progress(id int) (done, total int) {
+	for _, child := range tl.GetChildren(id) {
+		total++
+		if child.Completed {
+			done++
+		}
+	}
+	return done, total
+}
+
+// ArchiveWithChildren archives a todo and, with IncludeChildren, its whole
+// subtree. It returns how many todos were archived.
+func (tl *TodoList) ArchiveWithChildren(id int, policy ChildPolicy) int {
+	if !tl.Archive(id) {
+		return 0
+	}
+	count := 1
+	if policy == IncludeChildren {
+		for _, childID := range tl.GetDescendantIDs(id) {
+			if tl.Archive(childID) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// DeleteWithChildren deletes a todo and, with IncludeChildren, its whole
+// subtree. With KeepChildren the subtasks move up to the deleted todo's
+// parent. It returns how many todos were deleted.
+func (tl *TodoList) DeleteWithChildren(id int, policy ChildPolicy) int {
+	var ids []int
+	if policy == IncludeChildren {
+		ids = tl.GetDescendantIDs(id)
+	}
+	if !tl.Delete(id) {
+		return 0
+	}
+	count := 1
+	for _, childID := range ids {
+		if tl.Delete(childID) {
+			count++
+		}
+	}
+	return count
+}
+
+// detachChildren moves the subtasks of id up to newParentID.
+func (tl *TodoList) detachChildren(id, newParentID int) {
+	for i := range tl.Todos {
+		if tl.Todos[i].ParentID == id {
+			tl.Todos[i].ParentID = newParentID
+			tl.touch(i)
+		}
+	}
+}
+
+// syncParentCompletion completes a parent once all of its subtasks are
+// completed and reopens it when one of them is reopened, walking up the tree.
+func (tl *TodoList) syncParentCompletion(parentID int) {
+	idx := tl.findIndexByID(parentID)
+	if idx == -1 {
+		return
+	}
+	done, total := tl.Progress(parentID)
+	if total == 0 {
+		return
+	}
+	completed := done == total
+	if tl.Todos[idx].Completed == completed || completed && tl.IsBlocked(parentID) {
+		return
+	}
+	tl.setCompleted(idx, completed)
+	tl.syncParentCompletion(tl.Todos[idx].ParentID)
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeField names one of the moments recorded on a todo.
+type TimeField int
+
+const (
+	TimeCreated TimeField = iota
+	TimeModified
+	TimeCompleted
+	TimeArchived
+)
+
+// touch records that the todo at idx has just been changed.
+func (tl *TodoList) touch(idx int) {
+	tl.Todos[idx].ModifiedAt = time.Now()
+}
+
+// setCompleted marks the todo at idx completed or pending, recording when it
+// was completed. Completing an already completed todo keeps its time.
+func (tl *TodoList) setCompleted(idx int, completed bool) {
+	todo := &tl.Todos[idx]
+	if todo.Completed == completed {
+		return
+	}
+	todo.Completed = completed
+	todo.CompletedAt = nil
+	if completed {
+		now := time.Now()
+		todo.CompletedAt = &now
+	}
+	tl.touch(idx)
+}
+
+// setArchived archives or restores the todo at idx, recording when it was
+// archived. Archiving an already archived todo keeps its time.
+func (tl *TodoList) setArchived(idx int, archived bool) {
+	todo := &tl.Todos[idx]
+	if todo.Archived == archived {
+		return
+	}
+	todo.Archived = archived
+	todo.ArchivedAt = nil
+	if archived {
+		now := time.Now()
+		todo.ArchivedAt = &now
+	}
+	tl.touch(idx)
+}
+
+// Time returns the moment field was recorded on the todo. Todos completed or
+// archived before these times were tracked have none and report false.
+func (t Todo) Time(field TimeField) (time.Time, bool) {
+	switch field {
+	case TimeCreated:
+		return t.CreatedAt, true
+	case TimeModified:
+		return t.ModifiedAt, !t.ModifiedAt.IsZero()
+	case TimeCompleted:
+		if t.Completed && t.CompletedAt != nil {
+			return *t.CompletedAt, true
+		}
+	case TimeArchived:
+		if t.Archived && t.ArchivedAt != nil {
+			return *t.ArchivedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FilterByTime returns the todos whose field falls within [from, to). A zero
+// from or to leaves that end of the range open; if both are zero todos is
+// returned unchanged.
+func FilterByTime(todos []Todo, field TimeField, from, to time.Time) []Todo {
+	if from.IsZero() && to.IsZero() {
+		return todos
+	}
+	var filtered []Todo
+	for _, todo := range todos {
+		at, ok := todo.Time(field)
+		if !ok || !from.IsZero() && at.Before(from) || !to.IsZero() && !at.Before(to) {
+			continue
+		}
+		filtered = append(filtered, todo)
+	}
+	return filtered
+}
+
+// ParsePastTime parses a moment in the past for filters such as "since":
+// a relative age like "30m", "12h", "3d" or "2w", "today", "yesterday", or
+// a date like "2024-01-15" or "2024-01-15 15:30".
+func ParsePastTime(value string) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "":
+		return time.Time{}, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	relativePattern := regexp.MustCompile(`^(\d+)([mhdw])$`)
+	if matches := relativePattern.FindStringSubmatch(value); len(matches) == 3 {
+		n, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time value: %s", matches[1])
+		}
+		switch matches[2] {
+		case "m":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "h":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+
+	for _, format := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time: %s. Use formats like '3d', '2w', 'today', '2024-01-15' or '2024-01-15 15:30'", value)
+}
+
+// FormatTimestamp renders a recorded time for display, e.g.
+// "2024-01-15 15:30 (3h ago)".
+func FormatTimestamp(t time.Time) string {
+	ago := FormatTimeAgo(t)
+	if ago != "now" {
+		ago += " ago"
+	}
+	return fmt.Sprintf("%s (%s)", t.Format("2006-01-02 15:04"), ago)
+}
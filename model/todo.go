@@ -1,37 +1,53 @@
 package model
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Todo struct {
-	ID           int        `json:"id"`
-	Title        string     `json:"title"`
-	Completed    bool       `json:"completed"`
-	Archived     bool       `json:"archived"`
-	CreatedAt    time.Time  `json:"created_at"`
-	Deadline     *time.Time `json:"deadline,omitempty"`
-	HardDeadline bool       `json:"hard_deadline"`
+	ID           int           `json:"id"`
+	UUID         string        `json:"uuid,omitempty"`
+	Title        string        `json:"title"`
+	Completed    bool          `json:"completed"`
+	Archived     bool          `json:"archived"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Deadline     *time.Time    `json:"deadline,omitempty"`
+	HardDeadline bool          `json:"hard_deadline"`
+	Tags         []string      `json:"tags,omitempty"`
+	Project      string        `json:"project,omitempty"`
+	Priority     Priority      `json:"priority,omitempty"`
+	Notes        string        `json:"notes,omitempty"`
+	ParentID     int           `json:"parent_id,omitempty"`
+	DependsOn    []int         `json:"depends_on,omitempty"`
+	Recurrence   string        `json:"recurrence,omitempty"`
+	RecursFrom   int           `json:"recurs_from,omitempty"`
+	CompletedAt  *time.Time    `json:"completed_at,omitempty"`
+	ArchivedAt   *time.Time    `json:"archived_at,omitempty"`
+	ModifiedAt   time.Time     `json:"modified_at"`
+	History      []FieldChange `json:"history,omitempty"`
 }
 
 type TodoList struct {
-	Todos    []Todo      `json:"todos"`
-	NextID   int         `json:"next_id"`
-	TodoByID map[int]int `json:"-"`
+	SchemaVersion int         `json:"schema_version"`
+	Todos         []Todo      `json:"todos"`
+	NextID        int         `json:"next_id"`
+	TodoByID      map[int]int `json:"-"`
+	loaded        *snapshot
+	history       history
 }
 
 func NewTodoList() *TodoList {
 	return &TodoList{
-		Todos:    []Todo{},
-		NextID:   1,
-		TodoByID: make(map[int]int),
+		SchemaVersion: SchemaVersion,
+		Todos:         []Todo{},
+		NextID:        1,
+		TodoByID:      make(map[int]int),
+		history:       history{base: map[string]Todo{}},
 	}
 }
 
@@ -43,31 +59,27 @@ func (tl *TodoList) rebuildIndex() {
 }
 
 func (tl *TodoList) Add(title string) *Todo {
-	todo := Todo{
-		ID:           tl.NextID,
-		Title:        title,
-		Completed:    false,
-		Archived:     false,
-		CreatedAt:    time.Now(),
-		Deadline:     nil,
-		HardDeadline: false,
-	}
-	tl.Todos = append(tl.Todos, todo)
-	tl.TodoByID[todo.ID] = len(tl.Todos) - 1
-	tl.NextID++
-	return &todo
+	return tl.AddWithDeadline(title, nil, false)
 }
 
-// AddWithDeadline adds a new todo with an optional deadline
+// AddWithDeadline adds a new todo with an optional deadline. Any +tag words
+// and a project:name qualifier in the title are pulled out of it.
 func (tl *TodoList) AddWithDeadline(title string, deadline *time.Time, hardDeadline bool) *Todo {
+	title, project := ParseProject(title)
+	title, tags := ParseTags(title)
+	now := time.Now()
 	todo := Todo{
 		ID:           tl.NextID,
+		UUID:         newUUID(),
 		Title:        title,
 		Completed:    false,
 		Archived:     false,
-		CreatedAt:    time.Now(),
+		CreatedAt:    now,
 		Deadline:     deadline,
 		HardDeadline: hardDeadline,
+		Tags:         tags,
+		Project:      project,
+		ModifiedAt:   now,
 	}
 	tl.Todos = append(tl.Todos, todo)
 	tl.TodoByID[todo.ID] = len(tl.Todos) - 1
@@ -75,6 +87,79 @@ func (tl *TodoList) AddWithDeadline(title string, deadline *time.Time, hardDeadl
 	return &todo
 }
 
+// ParseTags splits "+tag" words out of a title, e.g. "Fix login +auth +urgent"
+// becomes "Fix login" with tags [auth urgent]. Duplicate tags are dropped.
+func ParseTags(title string) (string, []string) {
+	var words, tags []string
+	for _, word := range strings.Fields(title) {
+		if len(word) > 1 && strings.HasPrefix(word, "+") {
+			tag := NormalizeTag(word)
+			if !containsTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), tags
+}
+
+// NormalizeTag strips a leading "+" and lowercases the tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "+"))
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTag reports whether the todo carries the given tag. The tag may be
+// given with or without its leading "+".
+func (t Todo) HasTag(tag string) bool {
+	return containsTag(t.Tags, NormalizeTag(tag))
+}
+
+// FilterByTag returns the todos carrying tag, or todos unchanged if tag is empty.
+func FilterByTag(todos []Todo, tag string) []Todo {
+	if NormalizeTag(tag) == "" {
+		return todos
+	}
+	var filtered []Todo
+	for _, todo := range todos {
+		if todo.HasTag(tag) {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered
+}
+
+// GetTags returns every tag in use, sorted alphabetically.
+func (tl *TodoList) GetTags() []string {
+	var tags []string
+	for _, todo := range tl.Todos {
+		for _, tag := range todo.Tags {
+			if !containsTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// FormatTags renders tags the way they are typed, e.g. "+auth +urgent".
+func FormatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "+" + strings.Join(tags, " +")
+}
+
 // ParseDeadline parses deadline strings like "2h", "1d", "2024-01-15", "2024-01-15 15:30"
 func ParseDeadline(deadlineStr string) (*time.Time, error) {
 	if deadlineStr == "" {
@@ -134,12 +219,31 @@ func (tl *TodoList) findIndexByID(id int) int {
 	return -1
 }
 
+// Toggle flips the completion status of a todo. Completing a todo that is
+// still blocked by unfinished dependencies is refused; check Blockers first
+// to tell the user why, or use ForceToggle to complete it anyway.
 func (tl *TodoList) Toggle(id int) bool {
 	idx := tl.findIndexByID(id)
 	if idx == -1 {
 		return false
 	}
-	tl.Todos[idx].Completed = !tl.Todos[idx].Completed
+	if !tl.Todos[idx].Completed && tl.IsBlocked(id) {
+		return false
+	}
+	return tl.ForceToggle(id)
+}
+
+// ForceToggle flips the completion status of a todo even if it is blocked.
+func (tl *TodoList) ForceToggle(id int) bool {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return false
+	}
+	tl.setCompleted(idx, !tl.Todos[idx].Completed)
+	if tl.Todos[idx].Completed {
+		tl.spawnNextOccurrence(id)
+	}
+	tl.syncParentCompletion(tl.Todos[idx].ParentID)
 	return true
 }
 
@@ -148,7 +252,7 @@ func (tl *TodoList) Archive(id int) bool {
 	if idx == -1 {
 		return false
 	}
-	tl.Todos[idx].Archived = true
+	tl.setArchived(idx, true)
 	return true
 }
 
@@ -157,7 +261,18 @@ func (tl *TodoList) Unarchive(id int) bool {
 	if idx == -1 {
 		return false
 	}
-	tl.Todos[idx].Archived = false
+	tl.setArchived(idx, false)
+	return true
+}
+
+// SetNotes replaces the free-form notes of a todo.
+func (tl *TodoList) SetNotes(id int, notes string) bool {
+	idx := tl.findIndexByID(id)
+	if idx == -1 {
+		return false
+	}
+	tl.Todos[idx].Notes = notes
+	tl.touch(idx)
 	return true
 }
 
@@ -186,62 +301,33 @@ func (tl *TodoList) Delete(id int) bool {
 	if idx == -1 {
 		return false
 	}
+	parentID := tl.Todos[idx].ParentID
+	tl.detachChildren(id, parentID)
 	tl.Todos = append(tl.Todos[:idx], tl.Todos[idx+1:]...)
 	tl.rebuildIndex()
+	tl.removeDependenciesOn(id)
+	tl.syncParentCompletion(parentID)
 	return true
 }
 
+// Save writes the list to filename in the data directory through the
+// default JSON file store.
 func (tl *TodoList) Save(filename string) error {
-	dataDir, err := getDataDir()
-	if err != nil {
-		return err
-	}
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return err
-	}
-	data, err := json.Marshal(tl)
+	store, err := DefaultStore(filename)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(dataDir, filename), data, 0644)
+	return store.Save(tl)
 }
 
+// LoadTodoList reads filename from the data directory through the default
+// JSON file store.
 func LoadTodoList(filename string) (*TodoList, error) {
-	dataDir, err := getDataDir()
+	store, err := DefaultStore(filename)
 	if err != nil {
 		return nil, err
 	}
-	filePath := filepath.Join(dataDir, filename)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return NewTodoList(), nil
-	}
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-	var tl TodoList
-	if err := json.Unmarshal(data, &tl); err != nil {
-		return nil, err
-	}
-	for i, todo := range tl.Todos {
-		if todo.CreatedAt.IsZero() {
-			tl.Todos[i].CreatedAt = time.Now()
-		}
-	}
-	tl.TodoByID = make(map[int]int)
-	for i, todo := range tl.Todos {
-		tl.TodoByID[todo.ID] = i
-	}
-	return &tl, nil
-}
-
-func getDataDir() (string, error) {
-	cacheDir, err := os.UserCacheDir()
-	if err != nil {
-		return "", fmt.Errorf("could not determine user home directory: %w", err)
-	}
-	dataDir := filepath.Join(cacheDir, "togo")
-	return dataDir, nil
+	return store.Load()
 }
 
 func (tl *TodoList) GetTodoByID(id int) *Todo {
@@ -268,7 +354,7 @@ func (tl *TodoList) FindByTitle(title string, caseSensitive bool) (*Todo, bool)
 }
 
 func (tl *TodoList) DeleteByTitle(title string, caseSensitive bool) bool {
-	for i, todo := range tl.Todos {
+	for _, todo := range tl.Todos {
 		var matches bool
 		if caseSensitive {
 			matches = todo.Title == title
@@ -276,9 +362,7 @@ func (tl *TodoList) DeleteByTitle(title string, caseSensitive bool) bool {
 			matches = strings.EqualFold(todo.Title, title)
 		}
 		if matches {
-			tl.Todos = append(tl.Todos[:i], tl.Todos[i+1:]...)
-			tl.rebuildIndex()
-			return true
+			return tl.Delete(todo.ID)
 		}
 	}
 	return false
@@ -0,0 +1,71 @@
+package model
+
+// Export is the document written by togo export and read by togo import. The
+// short IDs inside it only tie its todos to each other; across lists todos
+// are identified by UUID. A todos.json file has the same shape, so one can be
+// imported directly.
+type Export struct {
+	SchemaVersion int    `json:"schema_version"`
+	Todos         []Todo `json:"todos"`
+}
+
+// ImportResult counts what Import did with each imported todo.
+type ImportResult struct {
+	Added     int
+	Updated   int
+	Unchanged int
+}
+
+// Import merges todos from another list. A todo whose UUID is already known
+// replaces the local copy if it was modified more recently; any other todo is
+// added with a new short ID. Subtask, dependency and recurrence links are
+// carried over by UUID, and links to todos missing from the import are dropped.
+func (tl *TodoList) Import(doc Export) ImportResult {
+	var result ImportResult
+	localIDs := make(map[int]int, len(doc.Todos))
+	isNew := make(map[int]bool, len(doc.Todos))
+	for i, todo := range doc.Todos {
+		if local := tl.GetTodoByUUID(todo.UUID); local != nil {
+			localIDs[todo.ID] = local.ID
+			continue
+		}
+		if todo.UUID == "" {
+			doc.Todos[i].UUID = newUUID()
+		}
+		localIDs[todo.ID] = tl.NextID
+		isNew[todo.ID] = true
+		tl.NextID++
+	}
+
+	for _, todo := range doc.Todos {
+		imported := todo
+		imported.ID = localIDs[todo.ID]
+		imported.ParentID = localIDs[todo.ParentID]
+		imported.RecursFrom = localIDs[todo.RecursFrom]
+		imported.Tags = append([]string(nil), todo.Tags...)
+		imported.DependsOn = nil
+		for _, dep := range todo.DependsOn {
+			if id, ok := localIDs[dep]; ok {
+				imported.DependsOn = append(imported.DependsOn, id)
+			}
+		}
+		if imported.ModifiedAt.IsZero() {
+			imported.ModifiedAt = imported.CreatedAt
+		}
+
+		if isNew[todo.ID] {
+			tl.Todos = append(tl.Todos, imported)
+			tl.TodoByID[imported.ID] = len(tl.Todos) - 1
+			result.Added++
+			continue
+		}
+		idx := tl.findIndexByID(imported.ID)
+		if !imported.ModifiedAt.After(tl.Todos[idx].ModifiedAt) {
+			result.Unchanged++
+			continue
+		}
+		tl.Todos[idx] = imported
+		result.Updated++
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// minUUIDPrefix is the shortest UUID prefix accepted when looking a todo up,
+// so that short numbers keep meaning short IDs.
+const minUUIDPrefix = 4
+
+// newUUID returns a fresh random UUID for a todo.
+func newUUID() string {
+	return uuid.NewString()
+}
+
+// ShortUUID returns the first eight characters of a UUID, enough to tell
+// todos apart when displaying them.
+func ShortUUID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// IsUUIDPrefix reports whether s could be the start of a UUID: at least four
+// hexadecimal digits, optionally with dashes.
+func IsUUIDPrefix(s string) bool {
+	if len(strings.ReplaceAll(s, "-", "")) < minUUIDPrefix {
+		return false
+	}
+	for _, r := range strings.ToLower(s) {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// HasUUIDPrefix reports whether the todo's UUID starts with prefix,
+// ignoring case.
+func (t Todo) HasUUIDPrefix(prefix string) bool {
+	return t.UUID != "" && IsUUIDPrefix(prefix) && strings.HasPrefix(t.UUID, strings.ToLower(prefix))
+}
+
+// FilterByUUIDPrefix returns the todos whose UUID starts with prefix.
+func FilterByUUIDPrefix(todos []Todo, prefix string) []Todo {
+	var filtered []Todo
+	for _, todo := range todos {
+		if todo.HasUUIDPrefix(prefix) {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered
+}
+
+// GetTodoByUUID returns the todo with the given UUID, or nil.
+func (tl *TodoList) GetTodoByUUID(id string) *Todo {
+	if id == "" {
+		return nil
+	}
+	for i := range tl.Todos {
+		if tl.Todos[i].UUID == id {
+			return &tl.Todos[i]
+		}
+	}
+	return nil
+}
+
+// backfillUUIDs gives a UUID to every todo that has none, such as todos in
+// files written before UUIDs existed.
+func (tl *TodoList) backfillUUIDs() {
+	for i := range tl.Todos {
+		if tl.Todos[i].UUID == "" {
+			tl.Todos[i].UUID = newUUID()
+		}
+	}
+}
@@ -0,0 +1,1142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prime-run/togo/model"
+)
+
+// TestParseTags tests that +tag words are pulled out of todo titles
+func TestParseTags(t *testing.T) {
+	title, tags := model.ParseTags("Fix login +auth +Urgent +auth")
+	if title != "Fix login" {
+		t.Errorf("expected title %q, got %q", "Fix login", title)
+	}
+	if len(tags) != 2 || tags[0] != "auth" || tags[1] != "urgent" {
+		t.Errorf("expected tags [auth urgent], got %v", tags)
+	}
+
+	// A lone "+" is part of the title, not a tag
+	title, tags = model.ParseTags("C + Go")
+	if title != "C + Go" || len(tags) != 0 {
+		t.Errorf("expected untouched title, got %q with tags %v", title, tags)
+	}
+}
+
+// TestFilterByTag tests tag filtering on a todo list
+func TestFilterByTag(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Fix login +auth")
+	todoList.Add("Deploy +infra")
+	todoList.Add("Rotate keys +auth +infra")
+
+	if got := model.FilterByTag(todoList.Todos, "+auth"); len(got) != 2 {
+		t.Errorf("expected 2 todos tagged auth, got %d", len(got))
+	}
+	if got := model.FilterByTag(todoList.Todos, ""); len(got) != 3 {
+		t.Errorf("expected empty tag to keep all todos, got %d", len(got))
+	}
+	if tags := todoList.GetTags(); len(tags) != 2 {
+		t.Errorf("expected 2 tags in use, got %v", tags)
+	}
+}
+
+// TestProjects tests project parsing, prefix queries and the rolled-up tree
+func TestProjects(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Fix login project:Work.API.auth")
+	todoList.Add("Deploy project:work.infra")
+	todoList.Add("Groceries project:home")
+	todoList.Add("Release project:work.api")
+	todoList.Toggle(4)
+
+	if todo := todoList.GetTodoByID(1); todo.Title != "Fix login" || todo.Project != "work.api.auth" {
+		t.Errorf("expected project to be parsed out of the title, got %q in %q", todo.Title, todo.Project)
+	}
+	if got := todoList.GetTodosByProject("work.api"); len(got) != 2 {
+		t.Errorf("expected 2 todos under work.api, got %d", len(got))
+	}
+	// "work.a" is not a parent of "work.api"
+	if got := todoList.GetTodosByProject("work.a"); len(got) != 0 {
+		t.Errorf("expected no todos under work.a, got %d", len(got))
+	}
+
+	tree := todoList.ProjectTree()
+	if len(tree) != 2 || tree[1].Name != "work" {
+		t.Fatalf("expected home and work at the top level, got %d nodes", len(tree))
+	}
+	work := tree[1]
+	if work.Pending != 2 || work.Completed != 1 {
+		t.Errorf("expected work to roll up 2 pending and 1 completed, got %d and %d", work.Pending, work.Completed)
+	}
+}
+
+// TestPriority tests priority parsing, JSON round-trips and sorting
+func TestPriority(t *testing.T) {
+	for input, want := range map[string]model.Priority{"H": model.PriorityHigh, "medium": model.PriorityMedium, "l": model.PriorityLow, "": model.PriorityNone} {
+		got, err := model.ParsePriority(input)
+		if err != nil || got != want {
+			t.Errorf("ParsePriority(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := model.ParsePriority("urgent"); err == nil {
+		t.Errorf("expected an error for an unknown priority")
+	}
+
+	todoList := model.NewTodoList()
+	todoList.Add("Low")
+	todoList.Add("None")
+	todoList.Add("High")
+	todoList.SetPriority(1, model.PriorityLow)
+	todoList.SetPriority(3, model.PriorityHigh)
+
+	data, err := json.Marshal(todoList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded model.TodoList
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	sorted := model.SortByPriority(loaded.Todos)
+	if sorted[0].Title != "High" || sorted[1].Title != "Low" || sorted[2].Title != "None" {
+		t.Errorf("expected High, Low, None order, got %s, %s, %s", sorted[0].Title, sorted[1].Title, sorted[2].Title)
+	}
+}
+
+// TestSubtasks tests progress, parent auto-completion and child policies
+func TestSubtasks(t *testing.T) {
+	todoList := model.NewTodoList()
+	parent := todoList.Add("Release")
+	first := todoList.Add("Write notes")
+	second := todoList.Add("Tag build")
+	grandchild := todoList.Add("Push tag")
+	todoList.SetParent(first.ID, parent.ID)
+	todoList.SetParent(second.ID, parent.ID)
+	todoList.SetParent(grandchild.ID, second.ID)
+
+	if err := todoList.SetParent(parent.ID, grandchild.ID); err == nil {
+		t.Errorf("expected an error when making a todo a subtask of its own subtask")
+	}
+
+	todoList.Toggle(first.ID)
+	if done, total := todoList.Progress(parent.ID); done != 1 || total != 2 {
+		t.Errorf("expected 1/2 progress, got %d/%d", done, total)
+	}
+	todoList.Toggle(grandchild.ID)
+	if !todoList.GetTodoByID(second.ID).Completed || !todoList.GetTodoByID(parent.ID).Completed {
+		t.Errorf("expected completing the last subtask to complete every ancestor")
+	}
+	todoList.Toggle(first.ID)
+	if todoList.GetTodoByID(parent.ID).Completed {
+		t.Errorf("expected reopening a subtask to reopen its parent")
+	}
+
+	if count := todoList.DeleteWithChildren(second.ID, model.KeepChildren); count != 1 {
+		t.Errorf("expected only the parent to be deleted, got %d", count)
+	}
+	if todoList.GetTodoByID(grandchild.ID).ParentID != parent.ID {
+		t.Errorf("expected the kept subtask to move up a level")
+	}
+	todoList.DeleteByTitle("write notes", false)
+	if !todoList.GetTodoByID(parent.ID).Completed {
+		t.Errorf("expected deleting the last open subtask by title to complete its parent")
+	}
+	if count := todoList.ArchiveWithChildren(parent.ID, model.IncludeChildren); count != 2 {
+		t.Errorf("expected the whole subtree to be archived, got %d", count)
+	}
+}
+
+// TestDependencies tests blocking, refused toggles and cycle detection
+func TestDependencies(t *testing.T) {
+	todoList := model.NewTodoList()
+	migrate := todoList.Add("Migrate database")
+	deploy := todoList.Add("Deploy")
+	announce := todoList.Add("Announce")
+
+	if err := todoList.AddDependency(deploy.ID, migrate.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := todoList.AddDependency(announce.ID, deploy.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := todoList.AddDependency(migrate.ID, announce.ID); err == nil {
+		t.Errorf("expected a cycle to be rejected")
+	}
+
+	if todoList.Toggle(deploy.ID) {
+		t.Errorf("expected completing a blocked todo to be refused")
+	}
+	if !todoList.IsBlocked(deploy.ID) || todoList.GetTodoByID(deploy.ID).Completed {
+		t.Errorf("expected deploy to stay blocked and pending")
+	}
+	todoList.Toggle(migrate.ID)
+	if todoList.IsBlocked(deploy.ID) || !todoList.Toggle(deploy.ID) {
+		t.Errorf("expected deploy to be unblocked once its dependency is completed")
+	}
+
+	todoList.Delete(deploy.ID)
+	if len(todoList.GetTodoByID(announce.ID).DependsOn) != 0 {
+		t.Errorf("expected dependencies on a deleted todo to be dropped")
+	}
+}
+
+// TestRecurrence tests repeat rules and the next instance spawned on completion
+func TestRecurrence(t *testing.T) {
+	friday := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"1w":            time.Date(2026, 1, 9, 9, 0, 0, 0, time.UTC),
+		"weekday":       time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		"every friday":  time.Date(2026, 1, 9, 9, 0, 0, 0, time.UTC),
+		"monthly on 31": time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+		"2m":            time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+	}
+	for rule, want := range cases {
+		recurrence, err := model.ParseRecurrence(rule)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q): %v", rule, err)
+			continue
+		}
+		if got := recurrence.Next(friday); !got.Equal(want) {
+			t.Errorf("%q: expected %v, got %v", rule, want, got)
+		}
+	}
+
+	todoList := model.NewTodoList()
+	todo := todoList.AddWithDeadline("Water plants", &friday, false)
+	if err := todoList.SetRecurrence(todo.ID, "weekly"); err != nil {
+		t.Fatal(err)
+	}
+	todoList.Toggle(todo.ID)
+	next := todoList.GetNextOccurrence(todo.ID)
+	if next == nil || next.Completed || !next.Deadline.Equal(friday.AddDate(0, 0, 7)) {
+		t.Fatalf("expected a pending instance due a week later, got %+v", next)
+	}
+	if history := todoList.GetOccurrenceHistory(next.ID); len(history) != 1 || history[0].ID != todo.ID {
+		t.Errorf("expected the new instance to link back to the completed one")
+	}
+
+	// Reopening and completing again must not spawn a duplicate
+	todoList.Toggle(todo.ID)
+	todoList.Toggle(todo.ID)
+	if len(todoList.Todos) != 2 {
+		t.Errorf("expected 2 todos, got %d", len(todoList.Todos))
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	todoList := model.NewTodoList()
+	todo := todoList.Add("Ship release")
+	if !todo.ModifiedAt.Equal(todo.CreatedAt) || todo.CompletedAt != nil || todo.ArchivedAt != nil {
+		t.Fatalf("expected a new todo to be unmodified, got %+v", todo)
+	}
+
+	todoList.Toggle(todo.ID)
+	todoList.Archive(todo.ID)
+	got := todoList.GetTodoByID(todo.ID)
+	if got.CompletedAt == nil || got.ArchivedAt == nil || got.ModifiedAt.Before(got.CreatedAt) {
+		t.Fatalf("expected completion and archive times, got %+v", got)
+	}
+	since := got.CreatedAt.Add(-time.Minute)
+	if n := len(model.FilterByTime(todoList.Todos, model.TimeCompleted, since, time.Time{})); n != 1 {
+		t.Errorf("expected 1 todo completed since %v, got %d", since, n)
+	}
+	if n := len(model.FilterByTime(todoList.Todos, model.TimeCompleted, time.Time{}, since)); n != 0 {
+		t.Errorf("expected no todo completed before %v, got %d", since, n)
+	}
+
+	// Reopening clears the completion time
+	todoList.Toggle(todo.ID)
+	if got := todoList.GetTodoByID(todo.ID); got.CompletedAt != nil {
+		t.Errorf("expected reopening to clear CompletedAt, got %v", got.CompletedAt)
+	}
+
+	// Files written before timestamps were tracked still decode
+	var old model.Todo
+	if err := json.Unmarshal([]byte(`{"id":1,"title":"Old","completed":true,"created_at":"2024-01-15T10:00:00Z"}`), &old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := old.Time(model.TimeCompleted); ok {
+		t.Errorf("expected no completion time for an old todo")
+	}
+
+	for _, value := range []string{"3d", "2w", "today", "2024-01-15"} {
+		if _, err := model.ParsePastTime(value); err != nil {
+			t.Errorf("ParsePastTime(%q): %v", value, err)
+		}
+	}
+	if _, err := model.ParsePastTime("soon"); err == nil {
+		t.Errorf("expected an error for an unknown time")
+	}
+}
+
+func TestUUIDs(t *testing.T) {
+	source := model.NewTodoList()
+	parent := source.Add("Plan trip")
+	child := source.Add("Book flights")
+	if parent.UUID == "" || parent.UUID == child.UUID {
+		t.Fatalf("expected distinct UUIDs, got %q and %q", parent.UUID, child.UUID)
+	}
+	if err := source.SetParent(child.ID, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if matches := model.FilterByUUIDPrefix(source.Todos, child.UUID[:6]); len(matches) != 1 || matches[0].ID != child.ID {
+		t.Errorf("expected the UUID prefix to match %q, got %v", child.Title, matches)
+	}
+	if model.IsUUIDPrefix("12") || model.IsUUIDPrefix("deploy") {
+		t.Errorf("expected short numbers and words not to be UUID prefixes")
+	}
+
+	// Importing into a list with its own IDs keeps links by UUID
+	target := model.NewTodoList()
+	target.Add("Unrelated")
+	result := target.Import(model.Export{Todos: source.Todos})
+	if result.Added != 2 {
+		t.Fatalf("expected 2 todos added, got %+v", result)
+	}
+	importedChild := target.GetTodoByUUID(child.UUID)
+	importedParent := target.GetTodoByUUID(parent.UUID)
+	if importedChild == nil || importedParent == nil || importedChild.ParentID != importedParent.ID {
+		t.Fatalf("expected the subtask link to survive the import")
+	}
+
+	// Importing again only updates todos that changed since
+	source.SetNotes(parent.ID, "Check visas")
+	result = target.Import(model.Export{Todos: source.Todos})
+	if result.Added != 0 || result.Updated != 1 || result.Unchanged != 1 {
+		t.Errorf("expected 1 updated and 1 unchanged, got %+v", result)
+	}
+	if got := target.GetTodoByUUID(parent.UUID); got.Notes != "Check visas" {
+		t.Errorf("expected the newer notes, got %q", got.Notes)
+	}
+}
+
+func TestSchemaMigration(t *testing.T) {
+	var todoList model.TodoList
+	old := `{"todos":[{"id":1,"title":"Old","completed":false,"archived":false,"created_at":"2024-01-15T10:00:00Z","hard_deadline":false}],"next_id":2}`
+	if err := json.Unmarshal([]byte(old), &todoList); err != nil {
+		t.Fatal(err)
+	}
+	from, err := model.Migrate(&todoList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 || todoList.SchemaVersion != model.SchemaVersion {
+		t.Errorf("expected an upgrade from 0 to %d, got %d to %d", model.SchemaVersion, from, todoList.SchemaVersion)
+	}
+	todo := todoList.Todos[0]
+	if todo.UUID == "" || !todo.ModifiedAt.Equal(todo.CreatedAt) {
+		t.Errorf("expected a UUID and a modification time, got %+v", todo)
+	}
+
+	newer := model.TodoList{SchemaVersion: model.SchemaVersion + 1}
+	var schemaErr *model.NewerSchemaError
+	if _, err := model.Migrate(&newer); !errors.As(err, &schemaErr) {
+		t.Errorf("expected a NewerSchemaError, got %v", err)
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	store := model.NewJSONFileStore(filepath.Join(t.TempDir(), "todos.json"))
+	setup := model.NewTodoList()
+	shared := setup.Add("Shared")
+	if err := store.Save(setup); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two processes load the same file and change different todos
+	tui, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.Add("Added from the CLI")
+	if err := store.Save(cli); err != nil {
+		t.Fatal(err)
+	}
+	tui.Toggle(shared.ID)
+	tui.Add("Added in the TUI")
+	if err := store.Save(tui); err != nil {
+		t.Fatalf("expected the changes to merge, got %v", err)
+	}
+
+	merged, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Todos) != 3 || !merged.GetTodoByUUID(shared.UUID).Completed {
+		t.Fatalf("expected both additions and the toggle, got %+v", merged.Todos)
+	}
+	ids := map[int]bool{}
+	for _, todo := range merged.Todos {
+		ids[todo.ID] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("expected distinct short IDs after merging, got %+v", merged.Todos)
+	}
+
+	// Changing the same todo in both is refused
+	other, _ := store.Load()
+	merged.SetNotes(shared.ID, "mine")
+	other.SetNotes(shared.ID, "theirs")
+	if err := store.Save(other); err != nil {
+		t.Fatal(err)
+	}
+	var conflict *model.ConflictError
+	if err := store.Save(merged); !errors.As(err, &conflict) {
+		t.Fatalf("expected a ConflictError, got %v", err)
+	}
+	reloaded, _ := store.Load()
+	if got := reloaded.GetTodoByUUID(shared.UUID).Notes; got != "theirs" {
+		t.Errorf("expected the saved notes to be kept, got %q", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := model.NewMemoryStore()
+	todoList, err := store.Load()
+	if err != nil || len(todoList.Todos) != 0 {
+		t.Fatalf("expected an empty list, got %v, %v", todoList, err)
+	}
+	todo := todoList.Add("Write tests")
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsaved changes stay out of the store
+	todoList.Toggle(todo.ID)
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.GetTodoByID(todo.ID)
+	if got == nil || got.Title != "Write tests" || got.Completed {
+		t.Errorf("expected the saved todo unchanged, got %+v", got)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	dir := t.TempDir()
+	store := model.NewSQLiteStore(filepath.Join(dir, "todos.db"))
+	todoList, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	parent := todoList.AddWithDeadline("Launch +web project:site", &deadline, true)
+	child := todoList.Add("Write copy")
+	todoList.SetParent(child.ID, parent.ID)
+	todoList.SetNotes(parent.ID, "line one\nline two")
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process adds a todo while this one completes the child
+	other, _ := store.Load()
+	other.Add("Added elsewhere")
+	if err := store.Save(other); err != nil {
+		t.Fatal(err)
+	}
+	todoList.Toggle(child.ID)
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Todos) != 3 || !loaded.GetTodoByUUID(parent.UUID).Completed {
+		t.Fatalf("expected 3 todos with the parent completed, got %+v", loaded.Todos)
+	}
+	got := loaded.GetTodoByUUID(parent.UUID)
+	if got.Notes != "line one\nline two" || !got.Deadline.Equal(deadline) || !got.HardDeadline || got.Project != "site" {
+		t.Errorf("expected every field to survive, got %+v", got)
+	}
+
+	// Converting to JSON and back is lossless
+	jsonStore := model.NewJSONFileStore(filepath.Join(dir, "todos.json"))
+	if n, err := model.CopyStore(store, jsonStore); err != nil || n != 3 {
+		t.Fatalf("expected 3 todos copied, got %d, %v", n, err)
+	}
+	if _, err := model.CopyStore(store, jsonStore); err == nil {
+		t.Errorf("expected copying into a non-empty store to fail")
+	}
+}
+
+// TestFinder tests looking todos up without loading the whole list, from the
+// SQLite indexes or by loading any other store
+func TestFinder(t *testing.T) {
+	dir := t.TempDir()
+	for _, store := range []model.Store{
+		model.NewSQLiteStore(filepath.Join(dir, "todos.db")),
+		model.NewJournalStore(model.NewJSONFileStore(filepath.Join(dir, "todos.json")), filepath.Join(dir, "todos.journal")),
+		model.NewJournalStore(model.NewSQLiteStore(filepath.Join(dir, "wrapped.db")), filepath.Join(dir, "wrapped.journal")),
+	} {
+		todoList, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		soon := time.Now().Add(time.Hour)
+		later := time.Now().Add(48 * time.Hour)
+		report := todoList.AddWithDeadline("Send report", &later, false)
+		invoice := todoList.AddWithDeadline("Pay invoice", &soon, false)
+		old := todoList.Add("Old idea")
+		todoList.Archive(old.ID)
+		if err := store.Save(todoList); err != nil {
+			t.Fatal(err)
+		}
+
+		ids := func(todos []model.Todo, err error) []int {
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, todo := range todos {
+				ids = append(ids, todo.ID)
+			}
+			return ids
+		}
+		if got, want := ids(model.ActiveTodos(store)), []int{report.ID, invoice.ID}; !slices.Equal(got, want) {
+			t.Errorf("%T: expected active todos %v, got %v", store, want, got)
+		}
+		if got, want := ids(model.ArchivedTodos(store)), []int{old.ID}; !slices.Equal(got, want) {
+			t.Errorf("%T: expected archived todos %v, got %v", store, want, got)
+		}
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	t.Setenv("TOGO_DATA_DIR", "")
+	if got, _ := model.DataDir(); got != filepath.Join(dir, "data", "togo") {
+		t.Errorf("expected the XDG data directory, got %s", got)
+	}
+	t.Setenv("TOGO_DATA_DIR", filepath.Join(dir, "env"))
+	if got, _ := model.DataDir(); got != filepath.Join(dir, "env") {
+		t.Errorf("expected TOGO_DATA_DIR, got %s", got)
+	}
+	model.SetDataDir(filepath.Join(dir, "flag"))
+	defer model.SetDataDir("")
+	if got, _ := model.DataDir(); got != filepath.Join(dir, "flag") {
+		t.Errorf("expected the --data-dir override, got %s", got)
+	}
+	if got, _ := model.UserDataDir(); got != filepath.Join(dir, "env") {
+		t.Errorf("expected the user's data directory to ignore the override, got %s", got)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	// Files left in the cache directory by older versions are moved
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	legacy := filepath.Join(dir, "cache", "togo")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "todos.json"), []byte(`{"todos":[],"next_id":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	movedFrom, err := model.MigrateLegacyDataDir()
+	if err != nil || movedFrom != legacy {
+		t.Fatalf("expected the files to move from %s, got %q, %v", legacy, movedFrom, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data", "togo", "todos.json")); err != nil {
+		t.Errorf("expected todos.json in the data directory: %v", err)
+	}
+	if movedFrom, _ := model.MigrateLegacyDataDir(); movedFrom != "" {
+		t.Errorf("expected nothing left to move, got %s", movedFrom)
+	}
+}
+
+func TestLists(t *testing.T) {
+	model.SetDataDir(t.TempDir())
+	defer model.SetDataDir("")
+	for _, name := range []string{"", "-work", "work/home", "a b"} {
+		if model.ValidateListName(name) == nil {
+			t.Errorf("expected %q to be rejected as a list name", name)
+		}
+	}
+
+	for _, name := range []string{"todos", "work"} {
+		store, err := model.DefaultStore(name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		todoList := model.NewTodoList()
+		todoList.Add("Task in " + name)
+		if err := store.Save(todoList); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names, _ := model.ListNames(".json"); len(names) != 2 || names[0] != "todos" || names[1] != "work" {
+		t.Fatalf("expected the lists todos and work, got %v", names)
+	}
+
+	if err := model.RenameList("work", "todos"); err == nil {
+		t.Errorf("expected renaming onto an existing list to fail")
+	}
+	if err := model.RenameList("work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := model.DefaultStore("job.json")
+	if todoList, err := store.Load(); err != nil || len(todoList.Todos) != 1 {
+		t.Errorf("expected the renamed list to keep its todo, got %v", err)
+	}
+	if err := model.DeleteList("job"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := model.ListNames(".json"); len(names) != 1 {
+		t.Errorf("expected only the todos list to be left, got %v", names)
+	}
+	if err := model.DeleteList("job"); err == nil {
+		t.Errorf("expected deleting a missing list to fail")
+	}
+}
+
+func TestFindLocal(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := model.FindLocal(nested); path != "" {
+		t.Fatalf("expected no project todos yet, got %s", path)
+	}
+
+	file := filepath.Join(root, model.LocalFileName)
+	if err := os.WriteFile(file, []byte(`{"todos":[],"next_id":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := model.FindLocal(nested); err != nil || path != file {
+		t.Errorf("expected %s to be found from a subdirectory, got %q, %v", file, path, err)
+	}
+
+	// The nearest project wins
+	dir := filepath.Join(root, "src", model.LocalDirName)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := model.FindLocal(nested); path != dir {
+		t.Errorf("expected the nearer %s, got %s", dir, path)
+	}
+
+	// The lock of a project list can be kept out of the project
+	store := model.NewJSONFileStore(file)
+	store.LockPath = filepath.Join(t.TempDir(), "state", "todos.json.lock")
+	if err := store.Save(model.NewTodoList()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("expected no lock next to %s, got %v", file, err)
+	}
+	if _, err := os.Stat(store.LockPath); err != nil {
+		t.Errorf("expected the lock at %s: %v", store.LockPath, err)
+	}
+}
+
+func TestBackups(t *testing.T) {
+	store := model.NewBackupStore(model.NewMemoryStore(), t.TempDir(), 3)
+	todoList, _ := store.Load()
+	first := todoList.Add("Write tests")
+	second := todoList.Add("Ship it")
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := store.Backups(); len(backups) != 0 {
+		t.Errorf("expected no snapshot of an empty store, got %d", len(backups))
+	}
+
+	todoList.Toggle(second.ID)
+	store.Save(todoList)
+	todoList.Toggle(first.ID)
+	store.Save(todoList)
+	todoList.Delete(second.ID)
+	store.Save(todoList)
+	todoList.Add("Celebrate")
+	store.Save(todoList)
+	// A save that changes nothing takes no snapshot
+	store.Save(todoList)
+	backups, err := store.Backups()
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("expected the 3 newest snapshots to be kept, got %d, %v", len(backups), err)
+	}
+
+	// The oldest snapshot kept was taken before the first todo was toggled
+	backup, err := model.FindBackup(backups, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := model.LoadBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := model.DiffLists(todoList, snapshot)
+	if len(diff.Added) != 1 || diff.Added[0].Title != "Ship it" || len(diff.Removed) != 1 || len(diff.Changed) != 1 {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	todoList.Restore(snapshot)
+	if todoList.GetTodoByID(second.ID) == nil || todoList.NextID != 4 {
+		t.Errorf("expected the deleted todo back without reusing IDs, next ID %d", todoList.NextID)
+	}
+}
+
+// TestConcurrentJournal tests that togo processes saving at the same time
+// keep every operation in the journal, even while one of them compacts it
+func TestConcurrentJournal(t *testing.T) {
+	dir := t.TempDir()
+	path, journal := filepath.Join(dir, "todos.json"), filepath.Join(dir, "todos.json.journal")
+	const writers, saves = 4, 60
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := model.NewJournalStore(model.NewJSONFileStore(path), journal)
+			for i := 0; i < saves; i++ {
+				todoList, err := store.Load()
+				if err == nil {
+					todoList.Add(fmt.Sprintf("Writer %d save %d", w, i))
+					err = store.Save(todoList)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// The journal was compacted along the way, and holds the latest
+	// operations without a gap: the adds of the todos created last
+	ops := mustReadJournal(t, journal)
+	if len(ops) >= writers*saves {
+		t.Errorf("expected the journal to be compacted, got %d entries", len(ops))
+	}
+	actions := map[string]bool{}
+	for _, op := range ops {
+		actions[op.Action] = true
+	}
+	store := model.NewJournalStore(model.NewJSONFileStore(path), journal)
+	todoList, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todoList.Todos) != writers*saves {
+		t.Fatalf("expected %d todos, got %d", writers*saves, len(todoList.Todos))
+	}
+	todos := slices.Clone(todoList.Todos)
+	slices.SortFunc(todos, func(a, b model.Todo) int {
+		return b.History[0].At.Compare(a.History[0].At)
+	})
+	for _, todo := range todos[:len(ops)] {
+		if action := fmt.Sprintf("add %q", todo.Title); !actions[action] {
+			t.Errorf("expected the journal to hold %s", action)
+		}
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	store := model.NewJournalStore(model.NewJSONFileStore(filepath.Join(dir, "todos.json")), filepath.Join(dir, "todos.json.journal"))
+	todoList, _ := store.Load()
+	todo := todoList.Add("Renew passport")
+	store.Save(todoList)
+	todoList.Toggle(todo.ID)
+	store.Save(todoList)
+
+	// Undo works across loads, newest operation first
+	todoList, _ = store.Load()
+	op, err := todoList.Undo()
+	if err != nil || op.Action != `complete "Renew passport"` {
+		t.Fatalf("expected to undo the completion, got %q, %v", op.Action, err)
+	}
+	if todoList.GetTodoByID(todo.ID).Completed {
+		t.Errorf("expected the todo to be pending again")
+	}
+	store.Save(todoList)
+
+	todoList, _ = store.Load()
+	if _, err := todoList.Redo(); err != nil || !todoList.GetTodoByID(todo.ID).Completed {
+		t.Errorf("expected redo to complete the todo again, %v", err)
+	}
+	if _, err := todoList.Redo(); !errors.Is(err, model.ErrNothingToRedo) {
+		t.Errorf("expected nothing left to redo, got %v", err)
+	}
+	store.Save(todoList)
+	if ops, _ := model.ReadJournal(store.Path); len(ops) != 4 {
+		t.Errorf("expected add, complete, undo and redo in the journal, got %d operations", len(ops))
+	}
+
+	// An undo that would overwrite a change made since is refused
+	other, _ := store.Load()
+	other.SetNotes(todo.ID, "Bring two photos")
+	store.Save(other)
+	todoList.Add("Book flights")
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+	if op, err := todoList.Undo(); err != nil || op.Action != `add "Book flights"` {
+		t.Errorf("expected to undo the add, got %q, %v", op.Action, err)
+	}
+	if _, err := todoList.Undo(); err == nil {
+		t.Errorf("expected undoing the completion to be refused after the notes changed")
+	}
+	for _, op := range mustReadJournal(t, store.Path) {
+		for _, change := range op.Changes {
+			if change.After != nil && (len(change.After.History) > 0 || change.Before != nil && len(change.Before.History) > 0) {
+				t.Errorf("expected no history in the journal entry of %s", op.Action)
+			}
+		}
+	}
+
+	// The journal is folded into the operations that can still be undone
+	// once it grows long
+	for i := 0; i < 250; i++ {
+		todoList.Toggle(todo.ID)
+		store.Save(todoList)
+	}
+	for i := 0; i < 60; i++ {
+		todoList.Undo()
+		store.Save(todoList)
+	}
+	if ops := mustReadJournal(t, store.Path); len(ops) > 200 {
+		t.Errorf("expected the journal to be compacted, it has %d operations", len(ops))
+	}
+	todoList, _ = store.Load()
+	if !todoList.CanUndo() || !todoList.CanRedo() {
+		t.Errorf("expected undo and redo to survive compaction")
+	}
+	for i := 0; i < 60; i++ {
+		if _, err := todoList.Redo(); err != nil {
+			t.Fatalf("expected to redo every undone toggle: %v", err)
+		}
+	}
+	if _, err := todoList.Redo(); !errors.Is(err, model.ErrNothingToRedo) {
+		t.Errorf("expected nothing left to redo, got %v", err)
+	}
+}
+
+func mustReadJournal(t *testing.T, path string) []model.Operation {
+	t.Helper()
+	ops, err := model.ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestHistory(t *testing.T) {
+	t.Setenv("TOGO_USER", "alice")
+	dir := t.TempDir()
+	store := model.NewJournalStore(model.NewJSONFileStore(filepath.Join(dir, "todos.json")), filepath.Join(dir, "todos.json.journal"))
+	todoList, _ := store.Load()
+	todo := todoList.Add("Deploy api")
+	store.Save(todoList)
+	todoList.SetPriority(todo.ID, model.PriorityHigh)
+	todoList.Toggle(todo.ID)
+	store.Save(todoList)
+	if _, err := todoList.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	store.Save(todoList)
+
+	todoList, _ = store.Load()
+	history := todoList.GetTodoByID(todo.ID).History
+	var fields []string
+	for _, change := range history {
+		fields = append(fields, change.Field+" "+change.Old+" "+change.New)
+	}
+	want := []string{"created  Deploy api", "completed false true", "priority  High", "completed true false", "priority High "}
+	if !slices.Equal(fields, want) {
+		t.Errorf("expected history %q, got %q", want, fields)
+	}
+	if history[0].By != "alice" {
+		t.Errorf("expected the changes to be by alice, got %q", history[0].By)
+	}
+	if got := history[1].String(); got != "completed: false → true" {
+		t.Errorf("unexpected description %q", got)
+	}
+
+	// Purging deletes archived todos and forgets them in the journal
+	old := todoList.Add("Old thing")
+	store.Save(todoList)
+	todoList.Archive(old.ID)
+	store.Save(todoList)
+	if purged := todoList.Purge(time.Now().Add(-time.Hour)); len(purged) != 0 {
+		t.Errorf("expected nothing archived an hour ago, purged %d", len(purged))
+	}
+	purged := todoList.Purge(time.Now().Add(time.Second))
+	if len(purged) != 1 || todoList.GetTodoByID(old.ID) != nil {
+		t.Fatalf("expected the archived todo to be purged, got %v", purged)
+	}
+	store.Save(todoList)
+	if err := store.Forget([]string{old.UUID}); err != nil {
+		t.Fatal(err)
+	}
+	ops, _ := model.ReadJournal(store.Path)
+	for _, op := range ops {
+		for _, change := range op.Changes {
+			if change.UUID == old.UUID {
+				t.Errorf("expected the purged todo to be gone from the journal, found in %q", op.Action)
+			}
+		}
+	}
+
+	modifiedAt := todoList.GetTodoByID(todo.ID).ModifiedAt
+	if dropped := todoList.CompactHistory(time.Now().Add(time.Second)); dropped != len(history) {
+		t.Errorf("expected all %d history entries to be dropped, got %d", len(history), dropped)
+	}
+	if compacted := todoList.GetTodoByID(todo.ID); compacted.History != nil || !compacted.ModifiedAt.After(modifiedAt) {
+		t.Errorf("expected no history left and the todo to count as modified")
+	}
+
+	// The copies in the backups are compacted too
+	backups := model.NewBackupStore(model.NewMemoryStore(), t.TempDir(), 3)
+	backedUp, _ := backups.Load()
+	backedUp.Add("Water plants")
+	backedUp.Commit("")
+	backups.Save(backedUp)
+	backedUp.Toggle(1)
+	backups.Save(backedUp)
+	if err := backups.CompactHistory(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := backups.Backups()
+	if len(list) != 1 {
+		t.Fatalf("expected a snapshot, got %d", len(list))
+	}
+	if snapshot, err := model.LoadBackup(list[0]); err != nil || snapshot.Todos[0].History != nil {
+		t.Errorf("expected the snapshot to lose its history, %v", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	dir := t.TempDir()
+	model.SetDataDir(dir)
+	defer model.SetDataDir("")
+	defer model.SetKey(nil)
+
+	path := filepath.Join(dir, "todos.json")
+	store := model.NewJournalStore(model.NewJSONFileStore(path), path+".journal")
+	todoList, _ := store.Load()
+	todoList.Add("Call ACME about the outage")
+	if err := store.Save(todoList); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := model.EncryptDataDir("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{path, path + ".journal"} {
+		data, _ := os.ReadFile(file)
+		if strings.Contains(string(data), "ACME") {
+			t.Errorf("expected %s to be encrypted", filepath.Base(file))
+		}
+		if info, err := os.Stat(file); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("expected %s to be readable by its owner only, got %v", filepath.Base(file), info.Mode().Perm())
+		}
+	}
+
+	model.SetKey(nil)
+	if _, err := store.Load(); !errors.Is(err, model.ErrEncrypted) {
+		t.Errorf("expected loading without the key to fail, got %v", err)
+	}
+	if err := model.Unlock("wrong"); !errors.Is(err, model.ErrWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase to be refused, got %v", err)
+	}
+	if err := model.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	todoList, err := store.Load()
+	if err != nil || len(todoList.Todos) != 1 {
+		t.Fatalf("expected to load the todo with the key, got %v", err)
+	}
+	if _, err := todoList.Undo(); err != nil {
+		t.Errorf("expected the encrypted journal to be read back, got %v", err)
+	}
+
+	if err := model.DecryptDataDir(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "ACME") {
+		t.Errorf("expected the list to be in plain text again")
+	}
+	if encrypted, _ := model.IsEncryptedDataDir(); encrypted {
+		t.Errorf("expected the data directory to be marked as plain text")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	todoList := model.NewTodoList()
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().AddDate(0, 0, 10)
+	deploy := todoList.AddWithDeadline("Deploy api project:work.api +backend", &soon, true)
+	docs := todoList.AddWithDeadline("Write deploy docs +docs", &later, false)
+	review := todoList.Add("Review PR +backend")
+	todoList.SetPriority(deploy.ID, model.PriorityHigh)
+	todoList.AddDependency(review.ID, deploy.ID)
+	todoList.Toggle(docs.ID)
+
+	for query, want := range map[string][]int{
+		"":       {deploy.ID, docs.ID, review.ID},
+		"deploy": {deploy.ID, docs.ID},
+		`status:pending due:<3d +backend -blocked title~"deploy"`: {deploy.ID},
+		"+backend -blocked":            {deploy.ID},
+		"+blocked":                     {review.ID},
+		"status:done or priority:high": {deploy.ID, docs.ID},
+		"not (+backend or +docs)":      {},
+		"project:work":                 {deploy.ID},
+		"priority:>=medium":            {deploy.ID},
+		"due:none":                     {review.ID},
+		"-status:completed and +docs":  {},
+		`"review pr"`:                  {review.ID},
+		"created:today":                {deploy.ID, docs.ID, review.ID},
+		"title~^write id:>1":           {docs.ID},
+	} {
+		q, err := model.ParseQuery(query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", query, err)
+			continue
+		}
+		var got []int
+		for _, todo := range q.Filter(todoList, todoList.Todos) {
+			got = append(got, todo.ID)
+		}
+		if !slices.Equal(got, want) && len(got)+len(want) > 0 {
+			t.Errorf("%q matched %v, want %v", query, got, want)
+		}
+	}
+
+	for query, column := range map[string]int{
+		"status:pending due:<3x": 21,
+		"stauts:pending":         1,
+		"(+backend or +docs":     1,
+		"+backend or":            12,
+		`title~"deploy`:          7,
+		"+backend )":             10,
+		"priority:urgent":        10,
+		"-status:maybe":          9,
+	} {
+		_, err := model.ParseQuery(query)
+		var queryErr *model.QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("expected %q to be refused, got %v", query, err)
+			continue
+		}
+		if queryErr.Column != column {
+			t.Errorf("%q: expected the error at column %d, got %d (%s)", query, column, queryErr.Column, queryErr.Msg)
+		}
+	}
+}
+
+func TestReports(t *testing.T) {
+	for name, report := range model.BuiltinReports {
+		if err := report.Validate(); err != nil {
+			t.Errorf("built-in report %s: %v", name, err)
+		}
+	}
+
+	todoList := model.NewTodoList()
+	past := time.Now().Add(-time.Hour)
+	soon := time.Now().Add(time.Hour)
+	late := todoList.AddWithDeadline("Renew domain", &past, true)
+	urgent := todoList.AddWithDeadline("Fix outage", &soon, true)
+	todoList.SetPriority(urgent.ID, model.PriorityHigh)
+	someday := todoList.Add("Learn Rust")
+	blocked := todoList.Add("Ship release")
+	todoList.AddDependency(blocked.ID, urgent.ID)
+
+	ids := func(todos []model.Todo) []int {
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+	todos, err := model.BuiltinReports["next"].Run(todoList)
+	if want := []int{urgent.ID, late.ID, someday.ID}; err != nil || !slices.Equal(ids(todos), want) {
+		t.Errorf("expected next to be %v, got %v (%v)", want, ids(todos), err)
+	}
+	todos, _ = model.BuiltinReports["overdue"].Run(todoList)
+	if want := []int{late.ID}; !slices.Equal(ids(todos), want) {
+		t.Errorf("expected overdue to be %v, got %v", want, ids(todos))
+	}
+	todos, _ = model.BuiltinReports["waiting"].Run(todoList)
+	if want := []int{blocked.ID}; !slices.Equal(ids(todos), want) {
+		t.Errorf("expected waiting to be %v, got %v", want, ids(todos))
+	}
+	if got := model.ColumnValue(todoList, todos[0], "blocked_by"); got != "Fix outage" {
+		t.Errorf("expected Ship release to be blocked by Fix outage, got %q", got)
+	}
+
+	// Todos without a deadline come last, whichever way deadlines are sorted
+	todos, _ = model.Report{Sort: []string{"-due"}, Limit: 3}.Run(todoList)
+	if want := []int{urgent.ID, late.ID, someday.ID}; !slices.Equal(ids(todos), want) {
+		t.Errorf("expected %v sorted by -due, got %v", want, ids(todos))
+	}
+
+	for _, report := range []model.Report{
+		{Filter: "status:"},
+		{Sort: []string{"size"}},
+		{Columns: []string{"id", "colour"}},
+	} {
+		if err := report.Validate(); err == nil {
+			t.Errorf("expected %+v to be refused", report)
+		}
+	}
+}
+
+func TestListItem(t *testing.T) {
+	todoList := model.NewTodoList()
+	release := todoList.Add("Ship release")
+	docs := todoList.Add("Write docs")
+	todoList.AddDependency(release.ID, docs.ID)
+
+	item := model.NewListItem(todoList, *todoList.GetTodoByUUID(release.UUID))
+	if item.Status != "blocked" || !item.Blocked || item.Priority != "none" {
+		t.Errorf("expected a blocked todo without priority, got %q, %v, %q", item.Status, item.Blocked, item.Priority)
+	}
+	if len(item.Record()) != len(model.ListItemFields) {
+		t.Errorf("expected %d fields in a record, got %d", len(model.ListItemFields), len(item.Record()))
+	}
+
+	// Scripts rely on every field being there, even when it is empty
+	data, err := json.Marshal(model.NewListItem(todoList, *todoList.GetTodoByUUID(docs.UUID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range append(model.ListItemFields, "history") {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("expected %q in %s", field, data)
+		}
+	}
+	if tags, ok := fields["tags"].([]any); !ok || len(tags) != 0 {
+		t.Errorf("expected tags to be [], got %v", fields["tags"])
+	}
+}
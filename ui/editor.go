@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notesEditedMsg is sent once the editor launched from the detail view exits.
+type notesEditedMsg struct {
+	id   int
+	path string
+	err  error
+}
+
+// EditorCommand builds the command that opens path in the user's editor,
+// taken from $VISUAL or $EDITOR and falling back to vi (notepad on Windows).
+// The variable may carry arguments, e.g. "code --wait". Blank variables are
+// skipped.
+func EditorCommand(path string) *exec.Cmd {
+	parts := strings.Fields(os.Getenv("VISUAL"))
+	if len(parts) == 0 {
+		parts = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(parts) == 0 {
+		parts = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			parts = []string{"notepad"}
+		}
+	}
+	cmd := exec.Command(parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// EditNotes opens notes in the user's editor and returns the saved text.
+func EditNotes(notes string) (string, error) {
+	path, err := writeNotesFile(notes)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+	if err := EditorCommand(path).Run(); err != nil {
+		return "", err
+	}
+	return readNotesFile(path)
+}
+
+// editNotesCmd suspends the program while the editor runs.
+func editNotesCmd(id int, notes string) tea.Cmd {
+	path, err := writeNotesFile(notes)
+	if err != nil {
+		return func() tea.Msg { return notesEditedMsg{id: id, err: err} }
+	}
+	return tea.ExecProcess(EditorCommand(path), func(err error) tea.Msg {
+		return notesEditedMsg{id: id, path: path, err: err}
+	})
+}
+
+func writeNotesFile(notes string) (string, error) {
+	f, err := os.CreateTemp("", "togo-notes-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(notes); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func readNotesFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
@@ -1,8 +1,11 @@
 package ui
 
 import (
+	"time"
+
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/prime-run/togo/model"
 )
 
@@ -16,6 +19,10 @@ const (
 	ModeAddTask
 	ModeAddTaskDeadline
 	ModeAddTaskDeadlineType
+	ModeFilterTag
+	ModeFilterProject
+	ModeSwitchList
+	ModeFilterQuery
 )
 
 type TodoTableModel struct {
@@ -24,21 +31,51 @@ type TodoTableModel struct {
 	err              error
 	mode             Mode
 	confirmAction    string
+	actionID         int
 	actionTitle      string
 	viewTaskID       int
+	notesViewport    viewport.Model
 	width            int
 	height           int
 	selectedTodoIDs  map[int]bool
+	collapsed        map[int]bool
 	bulkActionActive bool
 	textInput        textinput.Model
 	deadlineInput    textinput.Model
+	filterInput      textinput.Model
 	showArchived     bool
 	showAll          bool
 	showArchivedOnly bool
+	tagFilter        string
+	projectFilter    string
+	timeFilters      []timeFilter
+	query            *model.Query
+	sortKeys         []string
+	columns          []string
+	limit            int
+	queryErr         string
+	listName         string
+	listNames        []string
+	listCursor       int
+	switchList       ListSwitcher
 	statusMessage    string
 	showHelp         bool
+	// changed is set while handling a message that changed todos
+	changed bool
 	// Fields for add task flow
-	newTaskTitle     string
-	newTaskDeadline  string
+	newTaskTitle        string
+	newTaskDeadline     string
 	newTaskHardDeadline bool
+	newTaskParentID     int
+}
+
+// timeFilter keeps the todos whose field was recorded at or after since.
+type timeFilter struct {
+	field model.TimeField
+	since time.Time
+	label string
 }
+
+// ListSwitcher saves current, the list being shown, and loads the list called
+// name in its place.
+type ListSwitcher func(current *model.TodoList, name string) (*model.TodoList, error)
@@ -15,6 +15,8 @@ var (
 				Foreground(lipgloss.Color("28"))
 	statusPendingStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("136"))
+	statusBlockedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("167"))
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240"))
 	confirmStyle = lipgloss.NewStyle().
@@ -60,6 +62,17 @@ var (
 			Foreground(lipgloss.Color("246"))
 	archivedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
+	tagStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("69"))
+	priorityHighStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("160")).
+				Bold(true)
+	priorityMediumStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("214"))
+	priorityLowStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("67"))
+	projectStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("139"))
 	inputStyle = lipgloss.NewStyle().
 			BorderStyle(lipgloss.RoundedBorder()).
 			BorderForeground(lipgloss.Color("240")).
@@ -1,6 +1,11 @@
 package ui
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -68,7 +73,15 @@ func NewTodoTable(todoList *model.TodoList) TodoTableModel {
 	di.Placeholder = "Enter deadline (e.g., 2h, 1d, 2026-01-15) or press Enter to skip"
 	di.CharLimit = 50
 	di.Width = titleColWidth
-	
+
+	fi := textinput.New()
+	fi.Placeholder = "Enter a tag, or leave empty to clear the filter"
+	fi.CharLimit = 200
+	fi.Width = titleColWidth
+
+	// Changes made before the table opened are not part of its first action
+	todoList.Commit("")
+
 	showArchived := false
 	for _, todo := range todoList.Todos {
 		if todo.Archived {
@@ -86,9 +99,11 @@ func NewTodoTable(todoList *model.TodoList) TodoTableModel {
 		width:            displayWidth,
 		height:           24,
 		selectedTodoIDs:  make(map[int]bool),
+		collapsed:        make(map[int]bool),
 		bulkActionActive: false,
 		textInput:        ti,
 		deadlineInput:    di,
+		filterInput:      fi,
 		showArchived:     showArchived,
 		showAll:          true,
 		showArchivedOnly: false,
@@ -121,6 +136,239 @@ func (m *TodoTableModel) SetShowActiveOnly(show bool) {
 	*m = m.updateRows()
 }
 
+// SetTagFilter limits the table to todos carrying tag. An empty tag shows everything.
+func (m *TodoTableModel) SetTagFilter(tag string) {
+	m.tagFilter = model.NormalizeTag(tag)
+	*m = m.updateRows()
+}
+
+// SetProjectFilter scopes the table to one project subtree. An empty project shows everything.
+func (m *TodoTableModel) SetProjectFilter(project string) {
+	m.projectFilter = model.NormalizeProject(project)
+	*m = m.updateRows()
+}
+
+// AddTimeFilter limits the table to todos whose field was recorded at or
+// after since. label describes the filter in the title bar, e.g.
+// "completed since 1w".
+func (m *TodoTableModel) AddTimeFilter(field model.TimeField, since time.Time, label string) {
+	m.timeFilters = append(m.timeFilters, timeFilter{field: field, since: since, label: label})
+	*m = m.updateRows()
+}
+
+// SetQuery limits the table to the todos matching query. A nil query shows
+// everything.
+func (m *TodoTableModel) SetQuery(query *model.Query) {
+	m.query = query
+	*m = m.updateRows()
+}
+
+// SetSort orders the todos by the columns in keys, see model.SortTodos,
+// instead of by priority.
+func (m *TodoTableModel) SetSort(keys []string) {
+	m.sortKeys = keys
+	*m = m.updateRows()
+}
+
+// SetColumns shows the given columns, see model.Columns, after the
+// selection checkbox instead of the default ones. No columns restores them.
+func (m *TodoTableModel) SetColumns(columns []string) {
+	m.columns = columns
+	*m = m.updateRows()
+}
+
+// SetLimit shows at most limit todos, or all of them when it is 0.
+func (m *TodoTableModel) SetLimit(limit int) {
+	m.limit = limit
+	*m = m.updateRows()
+}
+
+// SetListSwitcher lets the user switch between the lists in names with L.
+// current is the name of the list being shown.
+func (m *TodoTableModel) SetListSwitcher(current string, names []string, switcher ListSwitcher) {
+	m.listName = current
+	m.listNames = names
+	m.switchList = switcher
+}
+
+// TodoList returns the list being shown, which changes when the user
+// switches lists.
+func (m TodoTableModel) TodoList() *model.TodoList {
+	return m.todoList
+}
+
+// treeRow is one table row: a todo and how deep it sits in the subtask tree.
+type treeRow struct {
+	todo  model.Todo
+	depth int
+}
+
+// visibleRows returns the rows the table currently shows. Subtasks follow
+// their parent, siblings are ordered by priority and then by the order they
+// were added, and collapsed subtrees are left out. A subtask whose parent is
+// filtered out is shown at the top level.
+func (m TodoTableModel) visibleRows() []treeRow {
+	var todos []model.Todo
+	if m.showAll {
+		todos = m.todoList.Todos
+	} else if m.showArchivedOnly {
+		todos = m.todoList.GetArchivedTodos()
+	} else {
+		todos = m.todoList.GetActiveTodos()
+	}
+	for _, filter := range m.timeFilters {
+		todos = model.FilterByTime(todos, filter.field, filter.since, time.Time{})
+	}
+	todos = model.SortByPriority(model.FilterByTag(model.FilterByProject(todos, m.projectFilter), m.tagFilter))
+	if m.query != nil {
+		todos = m.query.Filter(m.todoList, todos)
+	}
+	if len(m.sortKeys) > 0 {
+		todos = slices.Clone(todos)
+		model.SortTodos(m.todoList, todos, m.sortKeys)
+	}
+	if m.limit > 0 && len(todos) > m.limit {
+		todos = todos[:m.limit]
+	}
+
+	inView := make(map[int]bool, len(todos))
+	for _, todo := range todos {
+		inView[todo.ID] = true
+	}
+	var roots []model.Todo
+	children := make(map[int][]model.Todo)
+	for _, todo := range todos {
+		if todo.ParentID != 0 && inView[todo.ParentID] {
+			children[todo.ParentID] = append(children[todo.ParentID], todo)
+		} else {
+			roots = append(roots, todo)
+		}
+	}
+
+	var rows []treeRow
+	var walk func(todos []model.Todo, depth int)
+	walk = func(todos []model.Todo, depth int) {
+		for _, todo := range todos {
+			rows = append(rows, treeRow{todo: todo, depth: depth})
+			if !m.collapsed[todo.ID] {
+				walk(children[todo.ID], depth+1)
+			}
+		}
+	}
+	walk(roots, 0)
+	return rows
+}
+
+// VisibleTodos returns the todos the table shows with its current filters,
+// in row order, so they can be printed instead of shown.
+func (m TodoTableModel) VisibleTodos() []model.Todo {
+	return m.filteredTodos()
+}
+
+// filteredTodos returns the todos the table currently shows, in row order.
+func (m TodoTableModel) filteredTodos() []model.Todo {
+	rows := m.visibleRows()
+	todos := make([]model.Todo, len(rows))
+	for i, row := range rows {
+		todos[i] = row.todo
+	}
+	return todos
+}
+
+// setCursorToTodo moves the table cursor onto a todo if it is visible.
+func (m TodoTableModel) setCursorToTodo(id int) TodoTableModel {
+	for i, todo := range m.filteredTodos() {
+		if todo.ID == id {
+			m.table.SetCursor(i)
+			break
+		}
+	}
+	return m
+}
+
+func priorityStyle(priority model.Priority) lipgloss.Style {
+	switch priority {
+	case model.PriorityHigh:
+		return priorityHighStyle
+	case model.PriorityMedium:
+		return priorityMediumStyle
+	}
+	return priorityLowStyle
+}
+
+func renderPriority(priority model.Priority) string {
+	if priority == model.PriorityNone {
+		return ""
+	}
+	return priorityStyle(priority).Render(priority.Short())
+}
+
+// treePrefix indents a title by its depth in the subtask tree and marks
+// parents as expanded (▾) or collapsed (▸).
+func treePrefix(depth int, hasChildren, collapsed bool) string {
+	prefix := strings.Repeat("  ", depth)
+	if hasChildren {
+		if collapsed {
+			return prefix + "▸ "
+		}
+		return prefix + "▾ "
+	}
+	if depth > 0 {
+		return prefix + "  "
+	}
+	return prefix
+}
+
+// customColumns sizes the columns set with SetColumns to fit width: each as
+// wide as its widest value, up to 25 cells, with the title taking what is
+// left.
+func (m TodoTableModel) customColumns(width int) []table.Column {
+	columns := make([]table.Column, len(m.columns))
+	rows := m.visibleRows()
+	title := -1
+	for i, name := range m.columns {
+		columns[i] = table.Column{Title: columnTitle(name), Width: len(name)}
+		if name == "title" {
+			title = i
+			continue
+		}
+		for _, row := range rows {
+			columns[i].Width = max(columns[i].Width, lipgloss.Width(model.ColumnValue(m.todoList, row.todo, name)))
+		}
+		columns[i].Width = min(columns[i].Width, 25)
+		width -= columns[i].Width + 2
+	}
+	if title >= 0 {
+		columns[title].Width = max(width-2, 20)
+	}
+	return columns
+}
+
+// customCell renders a column set with SetColumns. The title and status are
+// shown the way the default columns show them.
+func (m TodoTableModel) customCell(todo model.Todo, column, title, status string) string {
+	switch column {
+	case "title":
+		return title
+	case "status":
+		return status
+	case "priority":
+		return renderPriority(todo.Priority)
+	case "tags":
+		return tagStyle.Render(model.FormatTags(todo.Tags))
+	}
+	return model.ColumnValue(m.todoList, todo, column)
+}
+
+// columnTitle is the header of a column set with SetColumns, e.g. "Blocked by".
+func columnTitle(column string) string {
+	if column == "id" || column == "uuid" {
+		return strings.ToUpper(column)
+	}
+	title := strings.ReplaceAll(column, "_", " ")
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
 // normalizeCells ensures that the row has exactly n cells, padding with empty strings
 // or truncating as needed to prevent index out of range errors during table rendering.
 func normalizeCells(cells []string, n int) []string {
@@ -149,9 +397,15 @@ func (m TodoTableModel) updateRows() TodoTableModel {
 	statusColWidth := 15
 	createdAtColWidth := 15
 	deadlineColWidth := 12
+	tagsColWidth := 15
+	priorityColWidth := 4
 	
 	// Calculate title column width with minimum constraint
-	titleColWidth := availableWidth - checkboxColWidth - statusColWidth - createdAtColWidth - deadlineColWidth - 8
+	titleColWidth := availableWidth - checkboxColWidth - priorityColWidth - statusColWidth - createdAtColWidth - deadlineColWidth - tagsColWidth - 12
+	if titleColWidth < 20 {
+		titleColWidth += tagsColWidth + 2
+		tagsColWidth = 0 // Hide tags column first when space gets tight
+	}
 	if titleColWidth < 20 {
 		titleColWidth = 20
 		deadlineColWidth = 0 // Hide deadline column if space is too tight
@@ -164,22 +418,22 @@ func (m TodoTableModel) updateRows() TodoTableModel {
 	if titleColWidth < 1 { titleColWidth = 1 }
 
 	// Build columns first to determine target layout
-	var columns []table.Column
+	columns := []table.Column{
+		{Title: "✓", Width: checkboxColWidth},
+		{Title: "Pri", Width: priorityColWidth},
+		{Title: "Title", Width: titleColWidth},
+	}
+	if tagsColWidth > 0 {
+		columns = append(columns, table.Column{Title: "Tags", Width: tagsColWidth})
+	}
+	columns = append(columns, table.Column{Title: "Status", Width: statusColWidth})
 	if deadlineColWidth > 0 {
-		columns = []table.Column{
-			{Title: "✓", Width: checkboxColWidth},
-			{Title: "Title", Width: titleColWidth},
-			{Title: "Status", Width: statusColWidth},
-			{Title: "Deadline", Width: deadlineColWidth},
-			{Title: "Created", Width: createdAtColWidth},
-		}
-	} else {
-		columns = []table.Column{
-			{Title: "✓", Width: checkboxColWidth},
-			{Title: "Title", Width: titleColWidth},
-			{Title: "Status", Width: statusColWidth},
-			{Title: "Created", Width: createdAtColWidth},
-		}
+		columns = append(columns, table.Column{Title: "Deadline", Width: deadlineColWidth})
+	}
+	columns = append(columns, table.Column{Title: "Created", Width: createdAtColWidth})
+	if len(m.columns) > 0 {
+		columns = m.customColumns(availableWidth - checkboxColWidth)
+		columns = append([]table.Column{{Title: "✓", Width: checkboxColWidth}}, columns...)
 	}
 
 	// Get the number of columns for normalization
@@ -187,17 +441,8 @@ func (m TodoTableModel) updateRows() TodoTableModel {
 
 	// Build rows with proper cell count normalization BEFORE setting anything on the table
 	var rows []table.Row
-	var filteredTodos []model.Todo
-
-	if m.showAll {
-		filteredTodos = m.todoList.Todos
-	} else if m.showArchivedOnly {
-		filteredTodos = m.todoList.GetArchivedTodos()
-	} else {
-		filteredTodos = m.todoList.GetActiveTodos()
-	}
-
-	for _, todo := range filteredTodos {
+	for _, row := range m.visibleRows() {
+		todo := row.todo
 		checkbox := checkboxEmpty
 		if m.selectedTodoIDs[todo.ID] {
 			checkbox = checkboxFilled
@@ -206,21 +451,41 @@ func (m TodoTableModel) updateRows() TodoTableModel {
 		if todo.Archived {
 			title = archivedStyle.Render(title)
 		}
+		done, total := m.todoList.Progress(todo.ID)
+		title = treePrefix(row.depth, total > 0, m.collapsed[todo.ID]) + title
+		progress := ""
+		if total > 0 {
+			progress = fmt.Sprintf(" %d/%d", done, total)
+		}
 		var status string
 		if todo.Completed {
-			status = statusCompleteStyle.Render("Completed")
+			status = statusCompleteStyle.Render("Completed" + progress)
+		} else if m.todoList.IsBlocked(todo.ID) {
+			status = statusBlockedStyle.Render("Blocked" + progress)
 		} else {
-			status = statusPendingStyle.Render("Pending")
+			status = statusPendingStyle.Render("Pending" + progress)
 		}
 		createdAt := model.FormatTimeAgo(todo.CreatedAt)
 		
 		// Build row with appropriate number of cells
-		var rowCells []string
+		rowCells := []string{checkbox, renderPriority(todo.Priority), title}
+		if tagsColWidth > 0 {
+			rowCells = append(rowCells, tagStyle.Render(model.FormatTags(todo.Tags)))
+		}
+		rowCells = append(rowCells, status)
 		if deadlineColWidth > 0 {
 			deadline := model.FormatDeadline(todo.Deadline, todo.HardDeadline)
-			rowCells = []string{checkbox, title, status, deadline, createdAt}
-		} else {
-			rowCells = []string{checkbox, title, status, createdAt}
+			if todo.Recurrence != "" {
+				deadline += " ↻"
+			}
+			rowCells = append(rowCells, deadline)
+		}
+		rowCells = append(rowCells, createdAt)
+		if len(m.columns) > 0 {
+			rowCells = []string{checkbox}
+			for _, column := range m.columns {
+				rowCells = append(rowCells, m.customCell(todo, column, title, status))
+			}
 		}
 		
 		// Normalize cells to match column count exactly
@@ -272,9 +537,9 @@ func (m TodoTableModel) updateRows() TodoTableModel {
 		if m.showHelp {
 			helpLines = 2
 			if m.bulkActionActive {
-				helpLines += 9
+				helpLines += 17
 			} else {
-				helpLines += 8
+				helpLines += 16
 			}
 		} else {
 			helpLines = 1
@@ -293,6 +558,16 @@ func (m TodoTableModel) findTodoByID(id int) *model.Todo {
 	return m.todoList.GetTodoByID(id)
 }
 
+// selectedTodo returns the todo under the table cursor, or nil if the table is empty.
+func (m TodoTableModel) selectedTodo() *model.Todo {
+	filteredTodos := m.filteredTodos()
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(filteredTodos) {
+		return nil
+	}
+	return m.findTodoByID(filteredTodos[cursor].ID)
+}
+
 func (m TodoTableModel) findTodoByTitle(title string) *model.Todo {
 	for i, todo := range m.todoList.Todos {
 		if todo.Title == title {
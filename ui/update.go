@@ -2,11 +2,14 @@ package ui
 
 import (
 	"fmt"
-	"strconv"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/prime-run/togo/model"
 )
 
@@ -15,9 +18,36 @@ func (m TodoTableModel) resetNewTaskFields() TodoTableModel {
 	m.newTaskTitle = ""
 	m.newTaskDeadline = ""
 	m.newTaskHardDeadline = false
+	m.newTaskParentID = 0
 	return m
 }
 
+// addNewTask adds the task being created, under its parent if it is a subtask.
+func (m TodoTableModel) addNewTask(deadline *time.Time) TodoTableModel {
+	todo := m.todoList.AddWithDeadline(m.newTaskTitle, deadline, m.newTaskHardDeadline)
+	if m.newTaskParentID != 0 {
+		if err := m.todoList.SetParent(todo.ID, m.newTaskParentID); err != nil {
+			m.SetStatusMessage(err.Error())
+		}
+		delete(m.collapsed, m.newTaskParentID)
+	}
+	m.changed = true
+	return m
+}
+
+// actionHasChildren reports whether the pending archive or delete touches a todo with subtasks.
+func (m TodoTableModel) actionHasChildren() bool {
+	if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
+		for id := range m.selectedTodoIDs {
+			if m.todoList.HasChildren(id) {
+				return true
+			}
+		}
+		return false
+	}
+	return m.todoList.HasChildren(m.actionID)
+}
+
 func (m TodoTableModel) createTaskWithDeadline() TodoTableModel {
 	if m.newTaskDeadline != "" {
 		deadline, err := model.ParseDeadline(m.newTaskDeadline)
@@ -27,11 +57,11 @@ func (m TodoTableModel) createTaskWithDeadline() TodoTableModel {
 			m.mode = ModeNormal
 			return m
 		}
-		m.todoList.AddWithDeadline(m.newTaskTitle, deadline, m.newTaskHardDeadline)
+		m = m.addNewTask(deadline)
 		deadlineStr := model.FormatDeadline(deadline, m.newTaskHardDeadline)
 		m.SetStatusMessage(fmt.Sprintf("Task added with deadline: %s", deadlineStr))
 	} else {
-		m.todoList.Add(m.newTaskTitle)
+		m = m.addNewTask(nil)
 		m.SetStatusMessage("New task added")
 	}
 	m = m.updateRows()
@@ -40,12 +70,126 @@ func (m TodoTableModel) createTaskWithDeadline() TodoTableModel {
 	return m
 }
 
+// openDetail switches to the detail view of a todo.
+func (m TodoTableModel) openDetail(id int) TodoTableModel {
+	m.mode = ModeViewDetail
+	m.viewTaskID = id
+	m.notesViewport = viewport.New(0, 0)
+	return m.refreshNotesViewport()
+}
+
+// refreshNotesViewport sizes the notes viewport to the window and loads the
+// notes of the todo being viewed.
+func (m TodoTableModel) refreshNotesViewport() TodoTableModel {
+	width := fullTaskViewStyle.GetWidth() - fullTaskViewStyle.GetHorizontalFrameSize()
+	height := m.height - 20
+	todo := m.findTodoByID(m.viewTaskID)
+	if todo != nil {
+		if lines := len(historyLines(*todo)); lines > 0 {
+			height -= lines + 2
+		}
+	}
+	if height < 3 {
+		height = 3
+	}
+	m.notesViewport.Width = width
+	m.notesViewport.Height = height
+	if todo != nil {
+		m.notesViewport.SetContent(lipgloss.NewStyle().Width(width).Render(todo.Notes))
+	}
+	return m
+}
+
+func (m TodoTableModel) applyEditedNotes(msg notesEditedMsg) TodoTableModel {
+	if msg.path != "" {
+		defer os.Remove(msg.path)
+	}
+	if msg.err != nil {
+		m.SetStatusMessage(fmt.Sprintf("Editor failed: %v", msg.err))
+		return m
+	}
+	notes, err := readNotesFile(msg.path)
+	if err != nil {
+		m.SetStatusMessage(fmt.Sprintf("Could not read notes: %v", err))
+		return m
+	}
+	m.todoList.SetNotes(msg.id, notes)
+	m.changed = true
+	m.SetStatusMessage("Notes saved")
+	return m.refreshNotesViewport()
+}
+
+// switchToList saves the list being shown and shows the list called name.
+func (m TodoTableModel) switchToList(name string) TodoTableModel {
+	m.mode = ModeNormal
+	if name == m.listName {
+		return m
+	}
+	todoList, err := m.switchList(m.todoList, name)
+	if err != nil {
+		m.SetStatusMessage(fmt.Sprintf("Could not switch list: %v", err))
+		return m
+	}
+	m.todoList = todoList
+	m.listName = name
+	m.selectedTodoIDs = make(map[int]bool)
+	m.bulkActionActive = false
+	m.collapsed = make(map[int]bool)
+	m.table.SetCursor(0)
+	m.SetStatusMessage("Switched to list " + name)
+	return m.updateRows()
+}
+
+// Update handles msg and, if it changed any todos, records the changes as
+// one operation, so that each action, including a bulk action, is undone in
+// one step.
 func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.update(msg)
+	if updated.changed {
+		updated.todoList.Commit("")
+		updated.changed = false
+	}
+	return updated, cmd
+}
+
+// undo reverts the last operation, or redoes the last undone one.
+func (m TodoTableModel) undo(redo bool) TodoTableModel {
+	var op model.Operation
+	var err error
+	if redo {
+		op, err = m.todoList.Redo()
+	} else {
+		op, err = m.todoList.Undo()
+	}
+	switch {
+	case err != nil:
+		m.SetStatusMessage(strings.ToUpper(err.Error()[:1]) + err.Error()[1:])
+	case redo:
+		m.SetStatusMessage("Redid " + op.Action)
+	default:
+		m.SetStatusMessage("Undid " + op.Action)
+	}
+	for id := range m.selectedTodoIDs {
+		if m.findTodoByID(id) == nil {
+			delete(m.selectedTodoIDs, id)
+		}
+	}
+	m.bulkActionActive = len(m.selectedTodoIDs) > 0
+	return m.updateRows()
+}
+
+func (m TodoTableModel) update(msg tea.Msg) (TodoTableModel, tea.Cmd) {
 	var cmd tea.Cmd
 	if msg, ok := msg.(tea.WindowSizeMsg); ok {
 		m.width = msg.Width
 		m.height = msg.Height
 		m = m.updateRows()
+		if m.mode == ModeViewDetail {
+			m = m.refreshNotesViewport()
+		}
+	}
+	if msg, ok := msg.(notesEditedMsg); ok {
+		return m.applyEditedNotes(msg), nil
 	}
 	switch m.mode {
 	case ModeViewDetail:
@@ -55,62 +199,58 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "esc", "q", "enter":
 				m.mode = ModeNormal
 				return m, nil
+			case "e":
+				if todo := m.findTodoByID(m.viewTaskID); todo != nil {
+					return m, editNotesCmd(todo.ID, todo.Notes)
+				}
+				return m, nil
 			}
 		}
-		return m, nil
+		m.notesViewport, cmd = m.notesViewport.Update(msg)
+		return m, cmd
 	case ModeDeleteConfirm, ModeArchiveConfirm:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
-			case "y", "Y":
+			case "y", "Y", "k", "K":
+				// With subtasks involved, y takes them along and k keeps them
+				policy := model.IncludeChildren
+				if msg.String() == "k" || msg.String() == "K" {
+					if !m.actionHasChildren() {
+						return m, nil
+					}
+					policy = model.KeepChildren
+				}
 				if m.mode == ModeDeleteConfirm {
 					if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
-						count := len(m.selectedTodoIDs)
+						count := 0
 						for id := range m.selectedTodoIDs {
-							m.todoList.Delete(id)
+							count += m.todoList.DeleteWithChildren(id, policy)
 						}
 						m.selectedTodoIDs = make(map[int]bool)
 						m.bulkActionActive = false
 						m.SetStatusMessage(fmt.Sprintf("%d tasks deleted", count))
-					} else {
-						found := false
-						for i, todo := range m.todoList.Todos {
-							if todo.Title == m.actionTitle || strings.Contains(m.actionTitle, todo.Title) {
-								m.todoList.Todos = append(m.todoList.Todos[:i], m.todoList.Todos[i+1:]...)
-								found = true
-								m.SetStatusMessage("Task deleted")
-								break
-							}
-						}
-						if !found {
-							id, err := strconv.Atoi(m.actionTitle)
-							if err == nil {
-								for _, todo := range m.todoList.Todos {
-									if todo.ID == id {
-										m.todoList.Delete(id)
-										m.SetStatusMessage("Task deleted")
-										break
-									}
-								}
-							}
-						}
+					} else if count := m.todoList.DeleteWithChildren(m.actionID, policy); count > 1 {
+						m.SetStatusMessage(fmt.Sprintf("Task and %d subtasks deleted", count-1))
+					} else if count == 1 {
+						m.SetStatusMessage("Task deleted")
 					}
 				} else if m.mode == ModeArchiveConfirm {
 					if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
+						count := 0
 						for id := range m.selectedTodoIDs {
-							m.todoList.Archive(id)
+							count += m.todoList.ArchiveWithChildren(id, policy)
 						}
 						m.selectedTodoIDs = make(map[int]bool)
 						m.bulkActionActive = false
-					} else {
-						for _, todo := range m.todoList.Todos {
-							if todo.Title == m.actionTitle {
-								m.todoList.Archive(todo.ID)
-								break
-							}
-						}
+						m.SetStatusMessage(fmt.Sprintf("%d tasks archived", count))
+					} else if count := m.todoList.ArchiveWithChildren(m.actionID, policy); count > 1 {
+						m.SetStatusMessage(fmt.Sprintf("Task and %d subtasks archived", count-1))
+					} else if count == 1 {
+						m.SetStatusMessage("Task archived")
 					}
 				}
+				m.changed = true
 				m = m.updateRows()
 				m.mode = ModeNormal
 				return m, nil
@@ -137,9 +277,7 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			case "esc":
 				m.textInput.Reset()
-				m.newTaskTitle = ""
-				m.newTaskDeadline = ""
-				m.newTaskHardDeadline = false
+				m = m.resetNewTaskFields()
 				m.mode = ModeNormal
 				return m, nil
 			}
@@ -159,7 +297,7 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				} else {
 					// No deadline, add task immediately
-					m.todoList.Add(m.newTaskTitle)
+					m = m.addNewTask(nil)
 					m = m.updateRows()
 					m.SetStatusMessage("New task added")
 					m = m.resetNewTaskFields()
@@ -194,6 +332,109 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		return m, cmd
+	case ModeFilterTag:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				m.SetTagFilter(m.filterInput.Value())
+				m.filterInput.Reset()
+				if m.tagFilter != "" {
+					m.SetStatusMessage("Filtering by +" + m.tagFilter)
+				} else {
+					m.SetStatusMessage("Tag filter cleared")
+				}
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			case "esc":
+				m.filterInput.Reset()
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			}
+		}
+		m.filterInput, cmd = m.filterInput.Update(msg)
+		return m, cmd
+	case ModeFilterQuery:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				text := strings.TrimSpace(m.filterInput.Value())
+				query, err := model.ParseQuery(text)
+				if err != nil {
+					m.queryErr = err.Error()
+					if queryErr, ok := err.(*model.QueryError); ok {
+						m.queryErr = queryErr.Pointer() + "\n" + err.Error()
+					}
+					return m, nil
+				}
+				if text == "" {
+					query = nil
+					m.SetStatusMessage("Query cleared")
+				} else {
+					m.SetStatusMessage("Filtering by " + text)
+				}
+				m.SetQuery(query)
+				m.filterInput.Reset()
+				m.queryErr = ""
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			case "esc":
+				m.filterInput.Reset()
+				m.queryErr = ""
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			}
+		}
+		m.filterInput, cmd = m.filterInput.Update(msg)
+		return m, cmd
+	case ModeFilterProject:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				m.SetProjectFilter(m.filterInput.Value())
+				m.filterInput.Reset()
+				if m.projectFilter != "" {
+					m.SetStatusMessage("Scoped to project " + m.projectFilter)
+				} else {
+					m.SetStatusMessage("Project scope cleared")
+				}
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			case "esc":
+				m.filterInput.Reset()
+				m.mode = ModeNormal
+				m = m.updateRows()
+				return m, nil
+			}
+		}
+		m.filterInput, cmd = m.filterInput.Update(msg)
+		return m, cmd
+	case ModeSwitchList:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "up", "k":
+				if m.listCursor > 0 {
+					m.listCursor--
+				}
+			case "down", "j":
+				if m.listCursor < len(m.listNames)-1 {
+					m.listCursor++
+				}
+			case "enter":
+				m = m.switchToList(m.listNames[m.listCursor])
+			case "esc", "q":
+				m.mode = ModeNormal
+			}
+		}
+		return m, nil
 	case ModeNormal:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -205,65 +446,60 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "esc", "q":
 				return m, tea.Quit
 			case "enter":
-				if len(m.table.Rows()) > 0 {
-					selectedRow := m.table.SelectedRow()
-					if len(selectedRow) > 1 {
-						selectedTitle := selectedRow[1]
-						cleanTitle := strings.Replace(selectedTitle, archivedStyle.Render(""), "", -1)
-
-						for _, todo := range m.todoList.Todos {
-							if strings.Contains(selectedTitle, todo.Title) || todo.Title == cleanTitle {
-								m.mode = ModeViewDetail
-								m.viewTaskID = todo.ID
-								break
-							}
-						}
-					}
+				if todo := m.selectedTodo(); todo != nil {
+					m = m.openDetail(todo.ID)
 				}
 			case "t":
 				if len(m.table.Rows()) > 0 {
 					if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
-						count := 0
+						count, blocked := 0, 0
 						for id := range m.selectedTodoIDs {
 							todo := m.findTodoByID(id)
 							if todo != nil {
 								if todo.Archived {
 									m.todoList.Unarchive(id)
 									count++
-								} else {
-									m.todoList.Toggle(id)
+								} else if m.todoList.Toggle(id) {
 									count++
+								} else {
+									blocked++
 								}
 							}
 						}
-						if count > 0 {
+						if blocked > 0 {
+							m.SetStatusMessage(fmt.Sprintf("%d tasks updated, %d blocked", count, blocked))
+						} else if count > 0 {
 							m.SetStatusMessage(fmt.Sprintf("%d tasks updated", count))
 						}
-					} else {
-						selectedRow := m.table.SelectedRow()
-						if len(selectedRow) > 1 {
-							selectedTitle := selectedRow[1]
-							cleanTitle := strings.Replace(selectedTitle, archivedStyle.Render(""), "", -1)
-
-							for _, todo := range m.todoList.Todos {
-								if strings.Contains(selectedTitle, todo.Title) || todo.Title == cleanTitle {
-									if todo.Archived {
-										m.todoList.Unarchive(todo.ID)
-										m.SetStatusMessage("Task unarchived")
-									} else {
-										m.todoList.Toggle(todo.ID)
-										m.SetStatusMessage("Task updated")
-									}
-									break
-								}
+					} else if todo := m.selectedTodo(); todo != nil {
+						if todo.Archived {
+							m.todoList.Unarchive(todo.ID)
+							m.SetStatusMessage("Task unarchived")
+						} else if blockers := m.todoList.Blockers(todo.ID); !todo.Completed && len(blockers) > 0 {
+							m.SetStatusMessage("Blocked by " + model.FormatTitles(blockers))
+						} else {
+							m.todoList.Toggle(todo.ID)
+							m.SetStatusMessage("Task updated")
+							if next := m.todoList.GetNextOccurrence(todo.ID); next != nil && m.findTodoByID(todo.ID).Completed {
+								m.SetStatusMessage("Task completed, next due " + next.Deadline.Format("2006-01-02"))
 							}
 						}
 					}
+					m.changed = true
 					m = m.updateRows()
 				}
 			case "n":
 				if len(m.table.Rows()) > 0 {
 					if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
+						// Ask what to do with subtasks before archiving any parent
+						for id := range m.selectedTodoIDs {
+							if todo := m.findTodoByID(id); todo != nil && !todo.Archived && m.todoList.HasChildren(id) {
+								m.mode = ModeArchiveConfirm
+								m.confirmAction = "archive"
+								m = m.updateRows()
+								return m, nil
+							}
+						}
 						count := 0
 						for id := range m.selectedTodoIDs {
 							todo := m.findTodoByID(id)
@@ -280,64 +516,119 @@ func (m TodoTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						if count > 0 {
 							m.SetStatusMessage(fmt.Sprintf("%d tasks updated", count))
 						}
-						m = m.updateRows()
-					} else {
-						selectedRow := m.table.SelectedRow()
-						if len(selectedRow) > 1 {
-							selectedTitle := selectedRow[1]
-							cleanTitle := strings.Replace(selectedTitle, archivedStyle.Render(""), "", -1)
-
-							for _, todo := range m.todoList.Todos {
-								if strings.Contains(selectedTitle, todo.Title) || todo.Title == cleanTitle {
-									if todo.Archived {
-										m.todoList.Unarchive(todo.ID)
-										m.SetStatusMessage("Task unarchived")
-									} else {
-										m.todoList.Archive(todo.ID)
-										m.SetStatusMessage("Task archived")
-									}
-									m = m.updateRows()
-									break
-								}
-							}
+					} else if todo := m.selectedTodo(); todo != nil {
+						if todo.Archived {
+							m.todoList.Unarchive(todo.ID)
+							m.SetStatusMessage("Task unarchived")
+						} else if m.todoList.HasChildren(todo.ID) {
+							m.mode = ModeArchiveConfirm
+							m.confirmAction = "archive"
+							m.actionID = todo.ID
+							m.actionTitle = todo.Title
+						} else {
+							m.todoList.Archive(todo.ID)
+							m.SetStatusMessage("Task archived")
 						}
 					}
+					m.changed = true
+					m = m.updateRows()
+				}
+			case "h", "left":
+				if todo := m.selectedTodo(); todo != nil {
+					if m.todoList.HasChildren(todo.ID) && !m.collapsed[todo.ID] {
+						m.collapsed[todo.ID] = true
+						m = m.updateRows()
+					} else if todo.ParentID != 0 {
+						// Already collapsed or a leaf: fold the parent and jump to it
+						m.collapsed[todo.ParentID] = true
+						m = m.updateRows()
+						m = m.setCursorToTodo(todo.ParentID)
+					}
+				}
+				return m, nil
+			case "l", "right":
+				if todo := m.selectedTodo(); todo != nil && m.collapsed[todo.ID] {
+					delete(m.collapsed, todo.ID)
+					m = m.updateRows()
+				}
+				return m, nil
+			case "A":
+				if todo := m.selectedTodo(); todo != nil {
+					m.newTaskParentID = todo.ID
+					m.mode = ModeAddTask
+					m.textInput.Focus()
+					return m, textinput.Blink
+				}
+			case "p":
+				if todo := m.selectedTodo(); todo != nil {
+					m.todoList.SetPriority(todo.ID, todo.Priority.Next())
+					m.changed = true
+					m.SetStatusMessage("Priority: " + m.findTodoByID(todo.ID).Priority.String())
+					m = m.updateRows()
 				}
 			case "a":
+				m.newTaskParentID = 0
 				m.mode = ModeAddTask
 				m.textInput.Focus()
 				return m, textinput.Blink
+			case "f":
+				m.mode = ModeFilterTag
+				m.filterInput.Placeholder = "Enter a tag, or leave empty to clear the filter"
+				m.filterInput.SetValue(m.tagFilter)
+				m.filterInput.Focus()
+				return m, textinput.Blink
+			case "s":
+				m.mode = ModeFilterProject
+				m.filterInput.Placeholder = "Enter a project, or leave empty to show all projects"
+				m.filterInput.SetValue(m.projectFilter)
+				m.filterInput.Focus()
+				return m, textinput.Blink
+			case "/":
+				m.mode = ModeFilterQuery
+				m.filterInput.Placeholder = `e.g. status:pending +backend due:<3d, or empty to clear`
+				m.filterInput.SetValue("")
+				if m.query != nil {
+					m.filterInput.SetValue(m.query.String())
+				}
+				m.filterInput.Focus()
+				return m, textinput.Blink
+			case "u":
+				// Handled here so the table doesn't take it as half page up
+				m = m.undo(false)
+				return m, nil
+			case "ctrl+r":
+				m = m.undo(true)
+				return m, nil
+			case "L":
+				if m.switchList == nil || len(m.listNames) < 2 {
+					m.SetStatusMessage("No other lists. Create one with 'togo lists create'")
+					return m, nil
+				}
+				m.mode = ModeSwitchList
+				m.listCursor = 0
+				for i, name := range m.listNames {
+					if name == m.listName {
+						m.listCursor = i
+					}
+				}
+				return m, nil
 			case "d":
 				if len(m.table.Rows()) > 0 {
 					if len(m.selectedTodoIDs) > 0 && m.bulkActionActive {
 						m.mode = ModeDeleteConfirm
 						m.confirmAction = "delete"
-					} else {
-						selectedRow := m.table.SelectedRow()
-						if len(selectedRow) > 1 {
-							selectedTitle := selectedRow[1]
-							cleanTitle := strings.Replace(selectedTitle, archivedStyle.Render(""), "", -1)
-
-							m.mode = ModeDeleteConfirm
-							m.confirmAction = "delete"
-							m.actionTitle = cleanTitle
-						}
+					} else if todo := m.selectedTodo(); todo != nil {
+						m.mode = ModeDeleteConfirm
+						m.confirmAction = "delete"
+						m.actionID = todo.ID
+						m.actionTitle = todo.Title
 					}
 				}
 			case " ":
 				if len(m.table.Rows()) > 0 {
 					selectedIndex := m.table.Cursor()
 					if selectedIndex >= 0 && selectedIndex < len(m.todoList.Todos) {
-						var filteredTodos []model.Todo
-
-						if m.showAll {
-							filteredTodos = m.todoList.Todos
-						} else if m.showArchivedOnly {
-							filteredTodos = m.todoList.GetArchivedTodos()
-						} else {
-							filteredTodos = m.todoList.GetActiveTodos()
-						}
-
+						filteredTodos := m.filteredTodos()
 						if selectedIndex < len(filteredTodos) {
 							todo := filteredTodos[selectedIndex]
 							if m.selectedTodoIDs[todo.ID] {
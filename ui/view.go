@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/prime-run/togo/model"
@@ -17,12 +18,17 @@ func (m TodoTableModel) View() string {
 		status := "Pending"
 		if todo.Completed {
 			status = statusCompleteStyle.Render("Completed")
+		} else if m.todoList.IsBlocked(todo.ID) {
+			status = statusBlockedStyle.Render("Blocked")
 		} else {
 			status = statusPendingStyle.Render("Pending")
 		}
 		archivedStatus := ""
 		if todo.Archived {
 			archivedStatus = "\nArchived: " + archivedStyle.Render("Yes")
+			if archivedAt, ok := todo.Time(model.TimeArchived); ok {
+				archivedStatus += " " + createdAtStyle.Render(model.FormatTimestamp(archivedAt))
+			}
 		}
 		
 		deadlineInfo := ""
@@ -36,12 +42,82 @@ func (m TodoTableModel) View() string {
 			deadlineInfo = fmt.Sprintf("\n%s: %s (%s)", deadlineType, deadlineStr, deadlineFormatted)
 		}
 		
-		createdAt := model.FormatTimeAgo(todo.CreatedAt)
+		priorityInfo := ""
+		if todo.Priority != model.PriorityNone {
+			priorityInfo = "\nPriority: " + priorityStyle(todo.Priority).Render(todo.Priority.String())
+		}
+
+		recurrenceInfo := ""
+		if todo.Recurrence != "" {
+			recurrenceInfo = "\nRepeats: " + model.FormatRecurrence(todo.Recurrence)
+			if next := m.todoList.GetNextOccurrence(todo.ID); next != nil && next.Deadline != nil {
+				recurrenceInfo += "\nNext due: " + next.Deadline.Format("2006-01-02 15:04") + fmt.Sprintf(" (task %d)", next.ID)
+			} else if nextDue := todo.NextDue(); nextDue != nil {
+				recurrenceInfo += "\nNext due: " + nextDue.Format("2006-01-02 15:04")
+			}
+			if history := m.todoList.GetOccurrenceHistory(todo.ID); len(history) > 0 {
+				recurrenceInfo += fmt.Sprintf("\nPrevious occurrences: %d", len(history))
+			}
+		}
+
+		subtaskInfo := ""
+		if parent := m.findTodoByID(todo.ParentID); parent != nil {
+			subtaskInfo += "\nSubtask of: " + parent.Title
+		}
+		if done, total := m.todoList.Progress(todo.ID); total > 0 {
+			subtaskInfo += fmt.Sprintf("\nSubtasks: %d/%d completed", done, total)
+		}
+
+		dependencyInfo := ""
+		if deps := m.todoList.GetDependencies(todo.ID); len(deps) > 0 {
+			dependencyInfo += "\nDepends on: " + model.FormatTitles(deps)
+		}
+		if blockers := m.todoList.Blockers(todo.ID); len(blockers) > 0 {
+			dependencyInfo += "\nBlocked by: " + statusBlockedStyle.Render(model.FormatTitles(blockers))
+		}
+		if dependents := m.todoList.GetDependents(todo.ID); len(dependents) > 0 {
+			dependencyInfo += "\nBlocks: " + model.FormatTitles(dependents)
+		}
+
+		projectInfo := ""
+		if todo.Project != "" {
+			projectInfo = "\nProject: " + projectStyle.Render(todo.Project)
+		}
+
+		tagsInfo := ""
+		if len(todo.Tags) > 0 {
+			tagsInfo = "\nTags: " + tagStyle.Render(model.FormatTags(todo.Tags))
+		}
+
+		notes := helpStyle.Render("No notes yet. Press e to add some.")
+		if todo.Notes != "" {
+			notes = "Notes:\n" + taskContentStyle.Render(m.notesViewport.View())
+			if !m.notesViewport.AtTop() || !m.notesViewport.AtBottom() {
+				notes += "\n" + helpStyle.Render(fmt.Sprintf("%3.f%% - ↑/↓ to scroll", m.notesViewport.ScrollPercent()*100))
+			}
+		}
+
+		identity := fmt.Sprintf("ID: %d", todo.ID)
+		if todo.UUID != "" {
+			identity += " " + createdAtStyle.Render(todo.UUID)
+		}
+		timestamps := identity + "\nCreated: " + createdAtStyle.Render(model.FormatTimestamp(todo.CreatedAt))
+		if completedAt, ok := todo.Time(model.TimeCompleted); ok {
+			timestamps += "\nCompleted: " + createdAtStyle.Render(model.FormatTimestamp(completedAt))
+		}
+		if !todo.ModifiedAt.IsZero() && !todo.ModifiedAt.Equal(todo.CreatedAt) {
+			timestamps += "\nModified: " + createdAtStyle.Render(model.FormatTimestamp(todo.ModifiedAt))
+		}
+		historyInfo := ""
+		if lines := historyLines(*todo); len(lines) > 0 {
+			historyInfo = "\n\nHistory:\n" + strings.Join(lines, "\n")
+		}
 		taskView := fullTaskViewStyle.Render(
 			taskTitleStyle.Render(todo.Title) + "\n\n" +
-				"Status: " + status + archivedStatus + deadlineInfo + "\n" +
-				"Created: " + createdAtStyle.Render(createdAt) + "\n\n" +
-				helpStyle.Render("Press Enter to go back"))
+				"Status: " + status + archivedStatus + priorityInfo + deadlineInfo + recurrenceInfo + subtaskInfo + dependencyInfo + projectInfo + tagsInfo + "\n" +
+				timestamps + historyInfo + "\n\n" +
+				notes + "\n\n" +
+				helpStyle.Render("Press e to edit notes, Enter to go back"))
 		return fullScreenStyle.Width(m.width).Height(m.height).Render(taskView)
 	}
 	if m.mode == ModeDeleteConfirm || m.mode == ModeArchiveConfirm {
@@ -55,14 +131,23 @@ func (m TodoTableModel) View() string {
 		} else {
 			confirmMessage = fmt.Sprintf("Are you sure you want to %s task: \"%s\"?", action, m.actionTitle)
 		}
+		buttons := confirmBtnStyle.Render("Y - Yes") + " " + cancelBtnStyle.Render("N - No")
+		if m.actionHasChildren() {
+			confirmMessage += "\nWhat should happen to the subtasks?"
+			buttons = confirmBtnStyle.Render("Y - "+action+" subtasks too") + " " +
+				confirmBtnStyle.Render("K - Keep subtasks") + " " + cancelBtnStyle.Render("N - No")
+		}
 		confirmBox := confirmStyle.Render(
-			confirmTextStyle.Render(confirmMessage) + "\n\n" +
-				confirmBtnStyle.Render("Y - Yes") + " " + cancelBtnStyle.Render("N - No"))
+			confirmTextStyle.Render(confirmMessage) + "\n\n" + buttons)
 		return fullScreenStyle.Width(m.width).Height(m.height).Render(confirmBox)
 	}
 	if m.mode == ModeAddTask {
+		prompt := "Add New Task"
+		if parent := m.findTodoByID(m.newTaskParentID); parent != nil {
+			prompt = fmt.Sprintf("Add Subtask to \"%s\"", parent.Title)
+		}
 		inputView := inputStyle.Render(
-			inputPromptStyle.Render("Add New Task") + "\n\n" +
+			inputPromptStyle.Render(prompt) + "\n\n" +
 				m.textInput.View() + "\n\n" +
 				helpStyle.Render("Press Enter to continue, Esc to cancel"))
 		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
@@ -81,6 +166,58 @@ func (m TodoTableModel) View() string {
 				helpStyle.Render("H - Hard deadline (important!)\nS/Enter - Soft deadline\nEsc - Cancel"))
 		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
 	}
+	if m.mode == ModeFilterTag {
+		tagsInUse := "No tags in use yet"
+		if tags := m.todoList.GetTags(); len(tags) > 0 {
+			tagsInUse = "Tags: " + model.FormatTags(tags)
+		}
+		inputView := inputStyle.Render(
+			inputPromptStyle.Render("Filter by Tag") + "\n\n" +
+				m.filterInput.View() + "\n\n" +
+				helpStyle.Render(tagsInUse+"\nPress Enter to apply, Esc to cancel"))
+		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
+	}
+	if m.mode == ModeFilterProject {
+		projectsInUse := "No projects in use yet"
+		if projects := m.todoList.GetProjects(); len(projects) > 0 {
+			projectsInUse = "Projects: " + strings.Join(projects, ", ")
+		}
+		inputView := inputStyle.Render(
+			inputPromptStyle.Render("Scope to Project") + "\n\n" +
+				m.filterInput.View() + "\n\n" +
+				helpStyle.Render(projectsInUse+"\nSub-projects are included. Press Enter to apply, Esc to cancel"))
+		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
+	}
+	if m.mode == ModeFilterQuery {
+		queryErr := ""
+		if m.queryErr != "" {
+			queryErr = statusBlockedStyle.Render(m.queryErr) + "\n\n"
+		}
+		inputView := inputStyle.Render(
+			inputPromptStyle.Render("Filter with a Query") + "\n\n" +
+				m.filterInput.View() + "\n\n" + queryErr +
+				helpStyle.Render("Terms: word, +tag, -tag, status:, priority:, project:, due:<3d, title~regexp\n"+
+					"Combine them with and, or, not and parentheses. Press Enter to apply, Esc to cancel"))
+		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
+	}
+	if m.mode == ModeSwitchList {
+		var lists strings.Builder
+		for i, name := range m.listNames {
+			line := "  " + name
+			if i == m.listCursor {
+				line = confirmTextStyle.Render("▶ " + name)
+			}
+			if name == m.listName {
+				line += helpStyle.Render(" (current)")
+			}
+			lists.WriteString(line + "\n")
+		}
+		inputView := inputStyle.Render(
+			inputPromptStyle.Render("Switch List") + "\n\n" +
+				lists.String() + "\n" +
+				helpStyle.Render("↑/↓ to choose, Enter to switch, Esc to cancel"))
+		return fullScreenStyle.Width(m.width).Height(m.height).Render(inputView)
+	}
 	if len(m.todoList.Todos) == 0 {
 		return baseStyle.Render("No tasks found. Press 'a' to add a new task!")
 	}
@@ -95,6 +232,21 @@ func (m TodoTableModel) View() string {
 	} else {
 		listTitle = "Active Tasks"
 	}
+	if len(m.listNames) > 1 {
+		listTitle = m.listName + ": " + listTitle
+	}
+	if m.projectFilter != "" {
+		listTitle += " " + projectStyle.Render(m.projectFilter)
+	}
+	if m.tagFilter != "" {
+		listTitle += " " + tagStyle.Render("+"+m.tagFilter)
+	}
+	for _, filter := range m.timeFilters {
+		listTitle += " " + createdAtStyle.Render(filter.label)
+	}
+	if m.query != nil {
+		listTitle += " " + createdAtStyle.Render(m.query.String())
+	}
 
 	leftSide := titleBarStyle.Render(listTitle)
 	rightSide := successMessageStyle.Render(m.statusMessage)
@@ -116,8 +268,16 @@ func (m TodoTableModel) View() string {
 			"\n→ n: toggle archive/unarchive for selected" +
 			"\n→ d: delete selected" +
 			"\n→ space: toggle selection" +
+			"\n→ p: cycle priority" +
+			"\n→ f: filter by tag" +
+			"\n→ s: scope to project" +
+			"\n→ /: filter with a query" +
 			"\n→ enter: view details" +
 			"\n→ a: add new task" +
+			"\n→ A: add subtask to selected" +
+			"\n→ h/l: collapse/expand subtasks" +
+			"\n→ L: switch list" +
+			"\n→ u/ctrl+r: undo/redo" +
 			"\n→ q: quit" +
 			"\n→ .: toggle help"
 	} else {
@@ -126,8 +286,16 @@ func (m TodoTableModel) View() string {
 			"\n→ n: toggle archive/unarchive" +
 			"\n→ d: delete" +
 			"\n→ space: select" +
+			"\n→ p: cycle priority" +
+			"\n→ f: filter by tag" +
+			"\n→ s: scope to project" +
+			"\n→ /: filter with a query" +
 			"\n→ enter: view details" +
 			"\n→ a: add new task" +
+			"\n→ A: add subtask to selected" +
+			"\n→ h/l: collapse/expand subtasks" +
+			"\n→ L: switch list" +
+			"\n→ u/ctrl+r: undo/redo" +
 			"\n→ q: quit" +
 			"\n→ .: toggle help"
 	}
@@ -143,3 +311,22 @@ func (m TodoTableModel) View() string {
 	}
 	return tableView
 }
+
+// detailHistoryLimit is how many of the latest history entries the detail
+// view shows.
+const detailHistoryLimit = 5
+
+// historyLines shows the latest changes to todo, one per line, for the
+// detail view.
+func historyLines(todo model.Todo) []string {
+	history := todo.History
+	var lines []string
+	if len(history) > detailHistoryLimit {
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("  %d earlier changes, see togo history", len(history)-detailHistoryLimit)))
+		history = history[len(history)-detailHistoryLimit:]
+	}
+	for _, change := range history {
+		lines = append(lines, "  "+createdAtStyle.Render(change.At.Local().Format("2006-01-02 15:04"))+" "+change.String())
+	}
+	return lines
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"github.com/prime-run/togo/model"
 	"github.com/prime-run/togo/ui"
@@ -182,4 +183,225 @@ func TestRapidResizeEvents(t *testing.T) {
 	if enterCmd != nil {
 		t.Logf("Table remains functional after rapid resize events")
 	}
-}
\ No newline at end of file
+}
+
+// TestTagFilterMode tests the tag filter prompt and the filtered table
+func TestTagFilterMode(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Fix login +auth")
+	todoList.Add("Deploy +infra")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'f'}})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("auth")})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	view := tableModel.View()
+	if !strings.Contains(view, "Fix login") {
+		t.Errorf("expected filtered table to show the +auth todo")
+	}
+	if strings.Contains(view, "Deploy") {
+		t.Errorf("expected filtered table to hide the +infra todo")
+	}
+}
+
+// TestDetailViewNotes tests that the detail view renders a todo's notes
+func TestDetailViewNotes(t *testing.T) {
+	todoList := model.NewTodoList()
+	todo := todoList.Add("Investigate crash")
+	todoList.SetNotes(todo.ID, "Repro: open the app\nand rotate the phone")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	view := tableModel.View()
+	if !strings.Contains(view, "Repro: open the app") {
+		t.Errorf("expected detail view to show the notes")
+	}
+
+	// Scrolling the notes must not leave the detail view
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if !strings.Contains(tableModel.View(), "Investigate crash") {
+		t.Errorf("expected to stay in the detail view while scrolling")
+	}
+}
+
+// TestEditorCommand tests that blank $VISUAL or $EDITOR fall back instead of
+// panicking
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "   ")
+	t.Setenv("EDITOR", "code --wait")
+	cmd := ui.EditorCommand("notes.md")
+	if got := strings.Join(cmd.Args, " "); got != "code --wait notes.md" {
+		t.Errorf("expected $EDITOR to be used, got %q", got)
+	}
+
+	t.Setenv("EDITOR", " ")
+	if cmd := ui.EditorCommand("notes.md"); len(cmd.Args) != 2 || cmd.Args[1] != "notes.md" {
+		t.Errorf("expected the default editor, got %v", cmd.Args)
+	}
+}
+
+// TestSubtaskCollapse tests collapsing and expanding a subtask tree
+func TestSubtaskCollapse(t *testing.T) {
+	todoList := model.NewTodoList()
+	parent := todoList.Add("Release")
+	child := todoList.Add("Write release notes")
+	todoList.SetParent(child.ID, parent.ID)
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if view := tableModel.View(); !strings.Contains(view, "Write release notes") || !strings.Contains(view, "0/1") {
+		t.Errorf("expected the subtask and the parent's progress to be shown")
+	}
+
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}})
+	if strings.Contains(tableModel.View(), "Write release notes") {
+		t.Errorf("expected h to collapse the subtree")
+	}
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	if !strings.Contains(tableModel.View(), "Write release notes") {
+		t.Errorf("expected l to expand the subtree")
+	}
+}
+
+// TestListSwitcher tests switching to another list with L
+func TestListSwitcher(t *testing.T) {
+	personal := model.NewTodoList()
+	personal.Add("Buy milk")
+	work := model.NewTodoList()
+	work.Add("Write report")
+
+	var saved *model.TodoList
+	tableModel := ui.NewTodoTable(personal)
+	tableModel.SetListSwitcher("personal", []string{"personal", "work"}, func(current *model.TodoList, name string) (*model.TodoList, error) {
+		saved = current
+		return work, nil
+	})
+
+	var m tea.Model = tableModel
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'L'}})
+	if !strings.Contains(m.View(), "work") {
+		t.Fatalf("expected the switcher to show the other list")
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if saved != personal {
+		t.Errorf("expected the list being left to be saved")
+	}
+	if m.(ui.TodoTableModel).TodoList() != work {
+		t.Errorf("expected the work list to be shown")
+	}
+	if view := m.View(); !strings.Contains(view, "Write report") || strings.Contains(view, "Buy milk") {
+		t.Errorf("expected the table to show the todos of the work list")
+	}
+}
+
+// TestUndoRedo tests that u undoes a bulk delete as one step and ctrl+r redoes it
+func TestUndoRedo(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Water plants")
+	todoList.Add("Pay rent")
+	todoList.Add("Call mom")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeySpace})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeySpace})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	if len(todoList.Todos) != 1 {
+		t.Fatalf("expected 2 todos to be deleted, %d left", len(todoList.Todos))
+	}
+
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if len(todoList.Todos) != 3 {
+		t.Errorf("expected u to bring back both todos, got %d", len(todoList.Todos))
+	}
+	if !strings.Contains(tableModel.View(), "Undid delete 2 todos") {
+		t.Errorf("expected the status bar to say what was undone")
+	}
+
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if len(todoList.Todos) != 1 {
+		t.Errorf("expected ctrl+r to delete them again, got %d", len(todoList.Todos))
+	}
+}
+
+// TestCommitOnlyOnChange tests that messages which change no todos, such as
+// resizes and cursor moves, don't record an operation
+func TestCommitOnlyOnChange(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Water plants")
+	todoList.Add("Pay rent")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	todoList.Add("Call mom")
+	tableModel, _ = tableModel.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	todoList.Commit("add outside the table")
+	if op, err := todoList.Undo(); err != nil || op.Action != "add outside the table" {
+		t.Errorf("expected the change made outside the table to stay uncommitted, undid %q, %v", op.Action, err)
+	}
+}
+
+func TestDetailHistory(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Water plants")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	view := tableModel.View()
+	if !strings.Contains(view, "History:") || !strings.Contains(view, `created "Water plants"`) {
+		t.Errorf("expected the detail view to show the history, got:\n%s", view)
+	}
+}
+
+func TestQueryFilterMode(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Fix login +auth")
+	todoList.Add("Deploy +infra")
+
+	var tableModel tea.Model = ui.NewTodoTable(todoList)
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("+auth or (")})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if view := tableModel.View(); !strings.Contains(view, "column 11") {
+		t.Errorf("expected the prompt to point at the error, got:\n%s", view)
+	}
+
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("(title~^fix)")})
+	tableModel, _ = tableModel.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	view := tableModel.View()
+	if !strings.Contains(view, "Fix login") || strings.Contains(view, "Deploy") {
+		t.Errorf("expected only the todo matching the query, got:\n%s", view)
+	}
+}
+
+// TestReportColumns tests that a report opened in the TUI keeps its columns
+// and limit
+func TestReportColumns(t *testing.T) {
+	todoList := model.NewTodoList()
+	todoList.Add("Write report project:work")
+	todoList.Add("Buy milk")
+	todoList.Add("Call mom")
+
+	tableModel := ui.NewTodoTable(todoList)
+	tableModel.SetSort([]string{"-id"})
+	tableModel.SetColumns([]string{"id", "title", "project"})
+	tableModel.SetLimit(2)
+	updated, _ := tableModel.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	view := updated.View()
+	if !strings.Contains(view, "Project") || strings.Contains(view, "Created") {
+		t.Errorf("expected the report's columns, got:\n%s", view)
+	}
+	if todos := updated.(ui.TodoTableModel).VisibleTodos(); len(todos) != 2 || todos[0].Title != "Call mom" {
+		t.Errorf("expected the first 2 todos by descending ID, got %v", todos)
+	}
+}